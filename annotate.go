@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// An annotation labels everything below a path prefix with whoever or whatever it belongs to, e.g. a team, a service
+// or a ticket, so findings can be routed to them.
+type annotation struct {
+	Prefix string
+	Label  string
+}
+
+// annotations is a set of annotations, read from a sidecar file by loadAnnotations.
+type annotations []annotation
+
+// loadAnnotations reads annotations from path.  Each line holds a path prefix, whitespace, and the label for
+// everything below the prefix, e.g. "/srv/kafka  data-platform team".  Prefixes with spaces can be written as Go
+// quoted strings.  Blank lines and lines starting with # are ignored.
+func loadAnnotations(path string) (annotations, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ans := annotations{}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix, label := "", ""
+		if strings.HasPrefix(line, `"`) {
+			quoted, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, fmt.Errorf("%v:%v: invalid quoted prefix: %v", path, n, err)
+			}
+			prefix, _ = strconv.Unquote(quoted)
+			label = line[len(quoted):]
+		} else if i := strings.IndexAny(line, " \t"); i > 0 {
+			prefix, label = line[:i], line[i:]
+		}
+		if label = strings.TrimSpace(label); prefix == "" || label == "" {
+			return nil, fmt.Errorf("%v:%v: expected a path prefix and a label", path, n)
+		}
+		ans = append(ans, annotation{Prefix: filepath.Clean(prefix), Label: label})
+	}
+	return ans, sc.Err()
+}
+
+// Label returns the label of the longest prefix matching p, or "" if none do.  Prefixes only match whole path
+// components, like those of -map-prefix.
+func (ans annotations) Label(p string) string {
+	best := -1
+	for i, a := range ans {
+		if (p == a.Prefix || strings.HasPrefix(p, a.Prefix+"/") || a.Prefix == "/") &&
+			(best < 0 || len(a.Prefix) > len(ans[best].Prefix)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return ans[best].Label
+}
+
+// annotated reports whether entries are annotated, by -annotations or by plugins.
+func (ro *reportOptions) annotated() bool {
+	return ro.Notes != nil || ro.Labels != nil
+}
+
+// annotation returns the annotation of fr: the one a plugin gave it, if any, or else the one of its path.
+func (ro *reportOptions) annotation(fr *FileRec) string {
+	if label, ok := ro.Labels[fr]; ok {
+		return label
+	}
+	return ro.Notes.Label(fr.Path())
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// An atomicFile is written under a temporary name next to its destination, and renamed into place once complete, so
+// readers of the destination never see it half-written.
+type atomicFile struct {
+	*os.File
+	path string // The destination.
+}
+
+// createAtomic creates a temporary file in the directory of path, to be renamed to path by Commit.
+func createAtomic(path string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	// CreateTemp makes the file private, but a report is no more sensitive than one written by shell redirection.
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &atomicFile{File: f, path: path}, nil
+}
+
+// Commit flushes the file to disk and renames it into place, replacing any previous file.  The temporary file is
+// removed if that fails.
+func (af *atomicFile) Commit() error {
+	err := af.Sync()
+	if cerr := af.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(af.Name(), af.path)
+	}
+	if err != nil {
+		os.Remove(af.Name())
+	}
+	return err
+}
+
+// Abort discards the file, leaving any previous file at the destination untouched.
+func (af *atomicFile) Abort() {
+	af.Close()
+	os.Remove(af.Name())
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// benchFilesPerDir is roughly how many files genTree puts in each leaf directory.
+const benchFilesPerDir = 100
+
+// genTree creates a synthetic tree of nFiles files under root, with leaf directories depth levels deep.  Files are
+// sparse, so their sizes are realistic without using up any disk.
+func genTree(root string, nFiles, depth int) error {
+	// Pick a fan-out giving about benchFilesPerDir files per leaf directory, but always branch a little.
+	fanOut := 2
+	if depth > 0 {
+		leaves := float64(nFiles) / benchFilesPerDir
+		fanOut = int(math.Max(2, math.Round(math.Pow(leaves, 1/float64(depth)))))
+	}
+
+	leafDirs := []string{root}
+	for d := 0; d < depth; d++ {
+		next := []string{}
+		for _, parent := range leafDirs {
+			for i := 0; i < fanOut; i++ {
+				dir := filepath.Join(parent, fmt.Sprintf("d%d", i))
+				if err := os.Mkdir(dir, 0755); err != nil {
+					return err
+				}
+				next = append(next, dir)
+			}
+		}
+		leafDirs = next
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < nFiles; i++ {
+		f, err := os.Create(filepath.Join(leafDirs[i%len(leafDirs)], fmt.Sprintf("f%d", i)))
+		if err != nil {
+			return err
+		}
+		err = f.Truncate(rnd.Int63n(1 << 24))
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchWorkers returns the worker counts to try by default: powers of two up to twice the number of CPUs, followed
+// by 0 for one walker per top-level entry.  Walkers are started per top-level entry, so counts beyond the entries,
+// of which there are top, are left out, as they'd make no difference.
+func benchWorkers(top int) []int {
+	w := []int{}
+	for n := 1; n <= 2*runtime.NumCPU() && n <= top; n *= 2 {
+		w = append(w, n)
+	}
+	return append(w, 0)
+}
+
+// runBench implements the bench subcommand, which times the scanner over a synthetic (or provided) tree under
+// several worker configurations.
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s bench [options]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	nFiles := fs.Float64("files", 1e5, "number of files in the synthetic tree")
+	depth := fs.Int("depth", 4, "depth of the synthetic tree")
+	dir := fs.String("dir", "", "benchmark an existing tree instead of generating one")
+	workerList := fs.String("workers", "",
+		"comma separated worker counts to try (defaults to powers of two up to 2x CPUs, and 0)")
+	runs := fs.Int("runs", 3, "number of timed runs per worker count; the fastest is reported")
+	fs.Parse(args)
+	if *runs < 1 {
+		log.Fatal("-runs must be at least 1")
+	}
+	var workers []int
+	if *workerList != "" {
+		for _, w := range strings.Split(*workerList, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(w))
+			if err != nil || n < 0 {
+				log.Fatalf("invalid worker count %q", w)
+			}
+			workers = append(workers, n)
+		}
+	}
+
+	// Once there's a generated tree, it's removed on the way out, including when failing.
+	tmp := ""
+	fatalf := func(format string, v ...interface{}) {
+		if tmp != "" {
+			os.RemoveAll(tmp)
+		}
+		log.Fatalf(format, v...)
+	}
+
+	root := *dir
+	if root == "" {
+		var err error
+		if tmp, err = os.MkdirTemp("", "bff-bench-"); err != nil {
+			log.Fatalf("failed to create benchmark tree: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+
+		log.Printf("generating %v files, %v levels deep, in %v", int(*nFiles), *depth, tmp)
+		if err := genTree(tmp, int(*nFiles), *depth); err != nil {
+			fatalf("failed to create benchmark tree: %v", err)
+		}
+		root = tmp
+	}
+
+	rootFileRec, err := NewFileRec(root)
+	if err != nil {
+		fatalf("failure in %v: %v", root, err)
+	}
+	top := len(rootFileRec.Contents)
+	if workers == nil {
+		workers = benchWorkers(top)
+	}
+
+	scan := func(w int) (*Results, time.Duration) {
+		rootFileRec, err := NewFileRec(root)
+		if err != nil {
+			fatalf("failure in %v: %v", root, err)
+		}
+		start := time.Now()
+		res := (&Scanner{FileLimit: 10, DirLimit: 10, Workers: w}).Scan(rootFileRec)
+		return res, time.Since(start)
+	}
+
+	// Run once untimed, so every configuration sees a warm cache.
+	scan(0)
+
+	tabW := &tabwriter.Writer{}
+	tabW.Init(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tabW, "Workers\tEntries\tBest time\tEntries/s")
+	for _, w := range workers {
+		best := time.Duration(math.MaxInt64)
+		entries := 0
+		for i := 0; i < *runs; i++ {
+			res, d := scan(w)
+			entries = res.Stats.Entries()
+			if d < best {
+				best = d
+			}
+		}
+		label := strconv.Itoa(w)
+		switch {
+		case w == 0:
+			label = "0 (per entry)"
+		case w > top:
+			label += fmt.Sprintf(" (as %v, one per entry)", top)
+		}
+		fmt.Fprintf(tabW, "%v\t%v\t%v\t%.0f\n", label, entries, best.Round(time.Millisecond),
+			float64(entries)/best.Seconds())
+	}
+	tabW.Flush()
+}
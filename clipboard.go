@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommands are the clipboard helpers tried, in order, on each platform.
+var clipboardCommands = map[string][][]string{
+	"darwin":  {{"pbcopy"}},
+	"windows": {{"clip.exe"}},
+	"linux":   {{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}},
+}
+
+// copyToClipboard places text on the system clipboard using the first helper available.  Without one it falls back
+// to an OSC 52 escape sequence, which most terminal emulators honor, even over ssh.
+func copyToClipboard(text string) error {
+	for _, argv := range clipboardCommands[runtime.GOOS] {
+		if _, err := exec.LookPath(argv[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(argv[0], argv[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("no clipboard helper found and no terminal to fall back to: %v", err)
+	}
+	defer tty.Close()
+	_, err = fmt.Fprintf(tty, "\x1b]52;c;%v\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	return err
+}
+
+// copyPaths copies the paths of the first n FileRecs of recs to the clipboard, one per line.
+func copyPaths(recs []*FileRec, n int) error {
+	if n > len(recs) {
+		n = len(recs)
+	}
+	paths := make([]string, 0, n)
+	for _, fr := range recs[:n] {
+		paths = append(paths, fr.Path())
+	}
+	return copyToClipboard(strings.Join(paths, "\n"))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Color modes accepted by -color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// SGR sequences for color output.  They all have the same length: tabwriter counts them as text, so columns still
+// line up as long as every cell of a column is painted.
+const (
+	sgrDefault = "\x1b[39m"
+	sgrRed     = "\x1b[31m"
+	sgrYellow  = "\x1b[33m"
+	sgrGreen   = "\x1b[32m"
+	sgrBlue    = "\x1b[34m"
+)
+
+// useColor decides whether to color the report for mode, one of the color modes.  In auto mode, color is used when
+// standard output is a terminal and NO_COLOR (https://no-color.org) isn't set.
+func useColor(mode string) (bool, error) {
+	switch mode {
+	case colorAlways:
+		return true, nil
+	case colorNever:
+		return false, nil
+	case colorAuto:
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		fi, err := os.Stdout.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0, nil
+	}
+	return false, fmt.Errorf("unknown color mode %q, use auto, always or never", mode)
+}
+
+// parseColorThresholds parses -color-thresholds, two comma separated sizes such as "1G,100M": sizes from the first
+// are red, sizes from the second are yellow and anything smaller is green.
+func parseColorThresholds(s string) (red, yellow int64, err error) {
+	strs := strings.Split(s, ",")
+	if len(strs) != 2 {
+		return 0, 0, fmt.Errorf("want two sizes, e.g. 1G,100M, got %q", s)
+	}
+	if red, err = parseSize(strs[0]); err != nil {
+		return 0, 0, err
+	}
+	if yellow, err = parseSize(strs[1]); err != nil {
+		return 0, 0, err
+	}
+	if yellow > red {
+		red, yellow = yellow, red
+	}
+	return red, yellow, nil
+}
+
+// paint returns s in the color set by sgr, if the report is in color.
+func (ro *reportOptions) paint(sgr, s string) string {
+	if !ro.Color {
+		return s
+	}
+	return sgr + s + sgrDefault
+}
+
+// paintCells paints each tab separated cell of s in the default color, so they line up with painted cells.
+func (ro *reportOptions) paintCells(s string) string {
+	if !ro.Color {
+		return s
+	}
+	cells := strings.Split(s, "\t")
+	for i, c := range cells {
+		cells[i] = ro.paint(sgrDefault, c)
+	}
+	return strings.Join(cells, "\t")
+}
+
+// sizeColor returns the color of a size, going by the report's thresholds.
+func (ro *reportOptions) sizeColor(n int64) string {
+	switch {
+	case n >= ro.RedSize:
+		return sgrRed
+	case n >= ro.YellowSize:
+		return sgrYellow
+	default:
+		return sgrGreen
+	}
+}
+
+// pathColor returns the color of the path of fr: directories stand out from files.
+func (ro *reportOptions) pathColor(fr *FileRec) string {
+	if fr.FileInfo.IsDir() {
+		return sgrBlue
+	}
+	return sgrDefault
+}
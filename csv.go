@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// csvHeader names the columns written by writeCSV.  The held_by and annotation columns follow, with
+// -attribute-process and with annotations.
+var csvHeader = []string{"path", "size", "kind", "mtime", "newest_mtime", "depth", "parent", "rel_path", "run_id",
+	"schema_version"}
+
+// csvTrailingHeader returns the names of the optional columns after csvHeader's, matching trailingCells.
+func csvTrailingHeader(opts *reportOptions) []string {
+	names := []string{}
+	if opts.Holders != nil {
+		names = append(names, "held_by")
+	}
+	if opts.annotated() {
+		names = append(names, "annotation")
+	}
+	return names
+}
+
+// csvRow converts fr, found in a scan of root, to a CSV row.
+func csvRow(fr *FileRec, root *FileRec, opts *reportOptions) []string {
+	newest := ""
+	if fr.FileInfo.IsDir() {
+		newest = fr.Newest.Format(time.RFC3339)
+	}
+	row := []string{
+		opts.path(fr),
+		strconv.FormatInt(fr.Size, 10),
+		fileType(fr.FileInfo),
+		fr.FileInfo.ModTime().Format(time.RFC3339),
+		newest,
+		strconv.Itoa(fr.Depth),
+		opts.mapPath(fr.Parent()),
+		opts.Redact.Redact(filepath.ToSlash(fr.RelPath(root.Path()))),
+		opts.Run.ID,
+		strconv.Itoa(jsonSchemaVersion),
+	}
+	return append(row, opts.trailingCells(fr)...)
+}
+
+// writeCSV writes res to w as CSV, with a header row and then one row per reported entry.  The run_id column ties
+// rows to the run that produced them.
+func writeCSV(w io.Writer, res *Results, opts *reportOptions) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append(append([]string{}, csvHeader...), csvTrailingHeader(opts)...))
+	for _, section := range []struct {
+		include bool
+		recs    []*FileRec
+	}{{opts.Files, res.Files}, {opts.Dirs, res.Dirs}, {opts.Special, res.Special}} {
+		if !section.include {
+			continue
+		}
+		for _, fr := range section.recs {
+			cw.Write(csvRow(fr, res.Root, opts))
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// deepen walks the tree below root breadth first, for scans with a time budget.  Every directory at one depth is read
+// before any at the next, so when the budget runs out the scan has covered the whole tree down to some depth, rather
+// than a few subtrees in full and the rest not at all.  Directories left unread for lack of time are counted as
+// unwalked, and like those which can't be read, left out.
+//
+// Files are sent on fileRecCh as they're found.  Directories are held back until the walk is over and then sent
+// deepest first, so that as with Walk, each follows everything below it.  deepen returns the newest mtime below root.
+func (s *Scanner) deepen(root *FileRec, fileRecCh chan *FileRec) time.Time {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	latest := time.Time{}
+	// The newest mtimes of directories are carried up as their entries are sent.  The root's is left to Scan.
+	newer := func(fr *FileRec) {
+		if fr.Dir != root {
+			if fr.Newest.After(fr.Dir.Newest) {
+				fr.Dir.Newest = fr.Newest
+			}
+		} else if fr.Newest.After(latest) {
+			latest = fr.Newest
+		}
+	}
+	send := func(fr *FileRec) {
+		atomic.AddInt64(&s.sending, 1)
+		fileRecCh <- fr
+		atomic.AddInt64(&s.sending, -1)
+	}
+
+	levels := [][]*FileRec{} // The directories read at each depth.
+	dirs := []*FileRec{root}
+	for depth := 1; len(dirs) > 0; depth++ {
+		found := []*FileRec{}
+		for _, dir := range dirs {
+			for _, fi := range dir.Contents {
+				fr := &FileRec{Name: fi.Name(), Dir: dir, FileInfo: fi, Size: fi.Size(), Depth: depth}
+				s.setMtime(fr)
+				if fi.IsDir() {
+					found = append(found, fr)
+					continue
+				}
+				newer(fr)
+				send(fr)
+			}
+			dir.Contents = nil
+		}
+		dirs = s.readLevel(found, workers)
+		levels = append(levels, dirs)
+	}
+
+	for i := len(levels) - 1; i >= 0; i-- {
+		for _, fr := range levels[i] {
+			newer(fr)
+			send(fr)
+		}
+	}
+	return latest
+}
+
+// readLevel reads dirs, the directories found at one depth, with up to workers go routines, and returns those which
+// were read.  Once the time budget is spent, the rest are left unread.
+func (s *Scanner) readLevel(dirs []*FileRec, workers int) []*FileRec {
+	read := make([]bool, len(dirs))
+	next := int64(-1)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers && w < len(dirs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(dirs) {
+					return
+				}
+				if time.Since(s.start) > s.Budget {
+					atomic.AddInt64(&s.unwalked, 1)
+					continue
+				}
+				read[i] = s.readContents(dirs[i], dirs[i].Path())
+			}
+		}()
+	}
+	wg.Wait()
+
+	kept := dirs[:0]
+	for i, fr := range dirs {
+		if read[i] {
+			kept = append(kept, fr)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// A deletedFile is a file which has been deleted, but still takes up space because processes hold it open.  It's the
+// usual reason df reports more space used than a scan finds.
+type deletedFile struct {
+	Path  string   // The path the file had when it was deleted.
+	Size  int64    // Size of the file.
+	Procs []string // The processes holding the file open, as "name (pid)".
+}
+
+// addProc records that the process pid, named comm, holds the file open.
+func (f *deletedFile) addProc(pid int, comm string) {
+	f.Procs = appendUnique(f.Procs, fmt.Sprintf("%v (%v)", comm, pid))
+}
+
+// sortDeletedFiles returns the files of m, largest first.
+func sortDeletedFiles(m map[fileKey]*deletedFile) []*deletedFile {
+	files := make([]*deletedFile, 0, len(m))
+	for _, f := range m {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Size != files[j].Size {
+			return files[i].Size > files[j].Size
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// reportDeletedFiles logs the deleted files still held open on the filesystem of root, of at least min bytes.
+func reportDeletedFiles(root *FileRec, min int64) {
+	key, _, ok := fileID(root.FileInfo)
+	if !ok {
+		log.Printf("can't look for deleted files: device numbers aren't available on this platform")
+		return
+	}
+	files, unreadable, err := openDeletedFiles(key.dev, min)
+	if err != nil {
+		log.Printf("failed to look for deleted files: %v", err)
+		return
+	}
+	total := int64(0)
+	for _, f := range files {
+		total += f.Size
+	}
+	if len(files) > 0 {
+		log.Printf("%v deleted files, taking up %v, are still held open on the filesystem of %v; the space is freed "+
+			"once they're closed, e.g. by restarting the processes:", len(files), humanSize(total), root.Path())
+		for _, f := range files {
+			log.Printf("  %v %v, held by %v", humanSize(f.Size), f.Path, strings.Join(f.Procs, ", "))
+		}
+	}
+	if unreadable > 0 {
+		log.Printf("the open files of %v processes couldn't be read; run as root to check them too", unreadable)
+	}
+}
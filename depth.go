@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// A depthRanking ranks the largest entries at each depth below the scan root, for drilling down level by level
+// rather than reading one global list.  Files are ranked as they're scanned; directories are ranked by their total
+// size once the directory tree is built.
+type depthRanking struct {
+	limit  int
+	files  []*ranking // The largest files at each depth, indexed by depth.
+	bytes  []int64    // Sum of the sizes of all files at each depth.
+	counts []int      // Number of files at each depth.
+}
+
+func newDepthRanking(limit int) *depthRanking {
+	return &depthRanking{limit: limit}
+}
+
+// Add offers fr to the ranking of its depth if it's a file.  It's meant to be used as a Scanner's Each function.
+func (dr *depthRanking) Add(fr *FileRec) {
+	if fr.FileInfo.IsDir() || isSpecial(fr.FileInfo) {
+		return
+	}
+	for len(dr.files) <= fr.Depth {
+		dr.files = append(dr.files, newRanking(dr.limit))
+		dr.bytes = append(dr.bytes, 0)
+		dr.counts = append(dr.counts, 0)
+	}
+	dr.files[fr.Depth].Offer(fr)
+	dr.bytes[fr.Depth] += fr.Size
+	dr.counts[fr.Depth]++
+}
+
+// A depthEntry is a file or directory in a depth report, with its size, which is the total size for a directory.
+type depthEntry struct {
+	Rec  *FileRec
+	Size int64
+}
+
+// A depthLevel is the largest entries at one depth, and what they leave out.
+type depthLevel struct {
+	Entries []depthEntry // Largest first.
+	Total   int64        // Size of everything at this depth.
+	Count   int          // Number of entries at this depth.
+}
+
+// levels merges the ranked files with the directories of st into the largest entries at each depth, from depth 1.
+func (dr *depthRanking) levels(st *sizeTree) []*depthLevel {
+	levels := []*depthLevel{}
+	level := func(depth int) *depthLevel {
+		for len(levels) < depth {
+			levels = append(levels, &depthLevel{})
+		}
+		return levels[depth-1]
+	}
+	for depth := 1; depth < len(dr.files); depth++ {
+		l := level(depth)
+		for _, fr := range dr.files[depth].Sorted() {
+			l.Entries = append(l.Entries, depthEntry{Rec: fr, Size: fr.Size})
+		}
+		l.Total += dr.bytes[depth]
+		l.Count += dr.counts[depth]
+	}
+	var add func(n *treeNode)
+	add = func(n *treeNode) {
+		for _, c := range n.Children {
+			l := level(c.Rec.Depth)
+			l.Entries = append(l.Entries, depthEntry{Rec: c.Rec, Size: c.Total})
+			l.Total += c.Total
+			l.Count++
+			add(c)
+		}
+	}
+	add(st.root)
+
+	for _, l := range levels {
+		sort.Slice(l.Entries, func(i, j int) bool {
+			if l.Entries[i].Size != l.Entries[j].Size {
+				return l.Entries[i].Size > l.Entries[j].Size
+			}
+			return comparePaths(l.Entries[i].Rec, l.Entries[j].Rec) < 0
+		})
+		if len(l.Entries) > dr.limit {
+			l.Entries = l.Entries[:dr.limit]
+		}
+	}
+	return levels
+}
+
+// newDepthWriter returns a reportWriter writing the largest entries at each depth of the scan, as ranked by dr, as
+// text.  It needs the directory tree for the totals of directories.
+func newDepthWriter(dr *depthRanking) reportWriter {
+	return func(w io.Writer, res *Results, opts *reportOptions) error {
+		if opts.Tree == nil {
+			return errors.New("the directory tree was dropped to save memory, so directory totals are unknown")
+		}
+		out := w
+		tabW := &tabwriter.Writer{}
+		if !opts.Plain {
+			tabW.Init(w, 0, 8, 2, ' ', 0)
+			out = tabW
+		}
+		rootSize := res.Stats.DirBytes
+		for i, l := range dr.levels(opts.Tree) {
+			fmt.Fprintln(out, opts.paintCells(opts.withMidHeaders(opts.header("depth.header", i+1))))
+			total, count := l.Total, l.Count
+			for _, e := range l.Entries {
+				fmt.Fprintf(out, "%v\t%v\n", opts.sizeCells(e.Rec, e.Size, rootSize, opts.sizeColor(e.Size)),
+					opts.paint(opts.pathColor(e.Rec), opts.path(e.Rec)))
+				total -= e.Size
+				count--
+			}
+			if count > 0 {
+				fmt.Fprintf(out, "%v\t%v\n", opts.sizeCells(nil, total, rootSize, sgrDefault),
+					opts.paint(sgrDefault, opts.msg("others.entries", count)))
+			}
+		}
+		if opts.Plain {
+			return nil
+		}
+		return tabW.Flush()
+	}
+}
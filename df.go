@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// A dfCheck tallies the disk space allocated to the entries scanned on the filesystem of the scan root, so the total
+// can be compared with what df reports as used once the scan is done.  That only makes sense when the root is the
+// top of a filesystem, as anything else leaves most of the filesystem out.
+type dfCheck struct {
+	root  string
+	dev   uint64
+	bytes int64
+}
+
+// newDFCheck returns a dfCheck for a scan of root, or an error if root isn't the top of a filesystem.
+func newDFCheck(root *FileRec) (*dfCheck, error) {
+	path := root.Path()
+	dev, bytes, ok := allocated(root.FileInfo)
+	if !ok {
+		return nil, fmt.Errorf("disk allocation isn't available on this platform")
+	}
+	if parent := filepath.Dir(path); parent != path {
+		fi, err := os.Lstat(parent)
+		if err != nil {
+			return nil, err
+		}
+		if parentDev, _, ok := allocated(fi); ok && parentDev == dev {
+			return nil, fmt.Errorf("%v isn't a mount point, so the scan doesn't cover its whole filesystem", path)
+		}
+	}
+	return &dfCheck{root: path, dev: dev, bytes: bytes}, nil
+}
+
+// Add counts the disk space allocated to fr, if it's on the root's filesystem.  It's meant to be used as a Scanner's
+// Each function.
+func (dc *dfCheck) Add(fr *FileRec) {
+	if dev, bytes, ok := allocated(fr.FileInfo); ok && dev == dc.dev {
+		dc.bytes += bytes
+	}
+}
+
+// Report warns if the disk space counted differs from the space df reports as used by more than tolerance percent,
+// and lists the likely causes, going by what the scan ran into.  filtered tells whether the scan left entries out on
+// purpose, e.g. by -exclude.
+func (dc *dfCheck) Report(res *Results, tolerance float64, filtered bool) {
+	used, err := fsUsed(dc.root)
+	if err != nil {
+		log.Printf("failed to get filesystem usage of %v: %v", dc.root, err)
+		return
+	}
+	diff := used - dc.bytes
+	if used == 0 || 100*float64(abs(diff))/float64(used) <= tolerance {
+		return
+	}
+
+	than := "less"
+	if diff < 0 {
+		than = "more"
+	}
+	log.Printf("WARNING: the scan found %v allocated on %v, but df reports %v used: %.1f%% %v", humanSize(dc.bytes),
+		dc.root, humanSize(used), 100*float64(abs(diff))/float64(used), than)
+	causes := []string{}
+	if diff > 0 {
+		if n := len(res.Denied); n > 0 {
+			causes = append(causes, fmt.Sprintf("%v paths couldn't be read for lack of permission", n))
+		}
+		if n := res.Stats.Errors; n > 0 {
+			causes = append(causes, fmt.Sprintf("%v entries couldn't be read", n))
+		}
+		if n := res.Stats.Unwalked; n > 0 {
+			causes = append(causes, fmt.Sprintf("%v directories were left unread when the time budget ran out", n))
+		}
+		if filtered {
+			causes = append(causes, "entries left out by -include, -exclude, -respect-gitignore or "+
+				"-skip-tcc-protected aren't counted")
+		}
+		causes = append(causes,
+			"files deleted while a process still has them open take up space until they're closed",
+			"files hidden underneath directories used as mount points can't be reached",
+			"filesystem metadata, such as a journal or snapshots, isn't in any directory")
+	} else {
+		causes = append(causes,
+			"deduplication, snapshots and shared extents (reflinks) let files share space each reports as its own",
+			"files may have been deleted while the scan ran")
+	}
+	for _, c := range causes {
+		log.Printf("  - %v", c)
+	}
+}
+
+// abs returns the absolute value of n.
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/trace"
+	"sync/atomic"
+	"time"
+)
+
+// servePprof exposes the net/http/pprof handlers on addr, e.g. ":6060".  It's meant to run as a go routine for the
+// lifetime of the scan.
+func servePprof(addr string) {
+	log.Printf("serving pprof on %v", addr)
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		log.Printf("pprof server failed: %v", err)
+	}
+}
+
+// startTrace starts a runtime execution trace written to path.  The returned function stops the trace and closes
+// the file, and must be called before the process exits for the trace to be usable.
+func startTrace(path string) (func(), error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := trace.Start(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		trace.Stop()
+		if err := f.Close(); err != nil {
+			log.Printf("failed to close trace %v: %v", path, err)
+		}
+	}, nil
+}
+
+// debugStats logs goroutine counts, the backlog of walkers blocked handing entries over to the scan, read atomically
+// from sending, and allocation stats every interval.  A backlog near the number of walkers means ranking, not
+// reading directories, is what holds the scan up.  It's meant to run as a go routine for the lifetime of the scan.
+func debugStats(interval time.Duration, sending *int64) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ms := runtime.MemStats{}
+		runtime.ReadMemStats(&ms)
+		log.Printf("goroutines: %v, blocked walkers: %v, heap: %v bytes, total alloc: %v bytes, mallocs: %v, "+
+			"gc cycles: %v", runtime.NumGoroutine(), atomic.LoadInt64(sending), ms.HeapAlloc, ms.TotalAlloc,
+			ms.Mallocs, ms.NumGC)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Modes of -du, named after the du options they mimic.
+const (
+	duAll     = "a" // Every entry, like du -ab.
+	duSummary = "s" // Just the scanned directory, like du -sb.
+)
+
+// checkDUMode returns an error if mode isn't one of the -du modes.
+func checkDUMode(mode string) error {
+	switch mode {
+	case duAll, duSummary:
+		return nil
+	}
+	return fmt.Errorf("unknown -du mode %q, use a (like du -ab) or s (like du -sb)", mode)
+}
+
+// A duWriter streams scanned entries in the output format of du: the apparent size in bytes, a tab and the path.
+// Like du, and unlike the other reports, a directory's size includes everything below it and the directory itself.
+type duWriter struct {
+	w       *bufio.Writer
+	summary bool               // Only write the root.
+	below   map[*FileRec]int64 // Bytes below each directory whose subdirectories were written, but not itself yet.
+	opts    *reportOptions
+}
+
+func newDUWriter(w io.Writer, mode string, opts *reportOptions) *duWriter {
+	return &duWriter{w: bufio.NewWriter(w), summary: mode == duSummary, below: map[*FileRec]int64{}, opts: opts}
+}
+
+// Write emits fr, unless only the root is written.  It's meant to be used as a Scanner's Each function, and for the
+// root once the scan is over.  Directories come after their contents, so their totals are known by then.
+func (dw *duWriter) Write(fr *FileRec) {
+	size := fr.Size
+	if fr.FileInfo.IsDir() {
+		// fr.Size covers the entries directly in fr, including the directories' own sizes, so only what's below
+		// its subdirectories needs adding.
+		below := fr.Size + dw.below[fr]
+		delete(dw.below, fr)
+		if fr.Dir != nil {
+			dw.below[fr.Dir] += below
+		}
+		size = below + fr.FileInfo.Size()
+	}
+	if dw.summary && fr.Dir != nil {
+		return
+	}
+	dw.w.WriteString(strconv.FormatInt(size, 10))
+	dw.w.WriteByte('\t')
+	dw.w.WriteString(dw.opts.path(fr))
+	dw.w.WriteByte('\n')
+}
+
+// Flush writes out any buffered output.
+func (dw *duWriter) Flush() error {
+	return dw.w.Flush()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// formatFolded is the name of the output format writing folded stacks instead of a report, like -flamegraph does to a
+// file.  It's written from every file scanned rather than from the results, so like ndjson it has no reportWriter.
+const formatFolded = "folded"
+
+// foldedFrames replaces the characters which the folded stacks format can't represent in a frame name.
+var foldedFrames = strings.NewReplacer(";", "_", "\n", "_", "\r", "_")
+
+// A foldedWriter writes scanned files as Brendan Gregg's "folded stacks": one line per file, made of the path
+// components below the scan root joined by semicolons, a space and the file size.  flamegraph.pl, speedscope and
+// d3-flamegraph's converters all turn this into a flame graph whose widths are bytes.
+type foldedWriter struct {
+	w    *bufio.Writer
+	root *FileRec
+	err  error // The first write error, if any.
+}
+
+func newFoldedWriter(w io.Writer, root *FileRec) *foldedWriter {
+	return &foldedWriter{w: bufio.NewWriter(w), root: root}
+}
+
+// Write adds fr to the output.  Directories and special files are skipped, as the widths of directory frames come
+// from the files below them.
+func (fw *foldedWriter) Write(fr *FileRec) {
+	if fw.err != nil || fr.FileInfo.IsDir() || isSpecial(fr.FileInfo) {
+		return
+	}
+	frames := strings.Split(fr.RelPath(fw.root.Path()), "/")
+	for i, f := range frames {
+		frames[i] = foldedFrames.Replace(f)
+	}
+	rootName := foldedFrames.Replace(fw.root.Path())
+	_, fw.err = fmt.Fprintf(fw.w, "%v;%v %v\n", rootName, strings.Join(frames, ";"), fr.Size)
+}
+
+// Flush writes out anything buffered, and returns the first error encountered.
+func (fw *foldedWriter) Flush() error {
+	if fw.err != nil {
+		return fw.err
+	}
+	return fw.w.Flush()
+}
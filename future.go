@@ -0,0 +1,24 @@
+package main
+
+import (
+	"time"
+)
+
+// futureSlack is how far past the start of a scan an mtime may be before it counts as future-dated.  It absorbs
+// small differences between clocks, e.g. those of a file server and of the host running the scan.
+const futureSlack = 5 * time.Minute
+
+// futureDated reports whether mt lies in the future, as seen from the start of the scan.  Such mtimes come from bad
+// clocks or restored backups, and would otherwise make every directory above them look newer than anything else.
+func (s *Scanner) futureDated(mt time.Time) bool {
+	return mt.After(s.start.Add(futureSlack))
+}
+
+// setMtime flags fr as future-dated if its mtime is in the future, and otherwise starts its Newest mtime off with
+// it.  Future-dated mtimes are left out of Newest, so they never surface as the newest mtime of a directory.
+func (s *Scanner) setMtime(fr *FileRec) {
+	mt := fr.FileInfo.ModTime()
+	if fr.Future = s.futureDated(mt); !fr.Future {
+		fr.Newest = mt
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A gitignoreRule is one pattern of a .gitignore file.
+type gitignoreRule struct {
+	pattern  []string // The components of the pattern, matched against the path relative to the .gitignore.
+	anchored bool     // The pattern had a slash before its end, so it matches relative paths, not base names.
+	negate   bool     // The pattern started with "!", re-including what earlier patterns ignored.
+	dirOnly  bool     // The pattern ended with a slash, so it only matches directories.
+}
+
+// parseGitignore parses the contents of a .gitignore file, as described by gitignore(5).
+func parseGitignore(data string) []gitignoreRule {
+	rules := []gitignoreRule{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+			line = line[:len(line)-1]
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := gitignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			r.negate, line = true, line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly, line = true, strings.TrimSuffix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.anchored = strings.Contains(line, "/")
+		r.pattern = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// match reports whether r matches rel, a slash separated path relative to the directory of the .gitignore.
+func (r gitignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.pattern[0], path.Base(rel))
+		return ok
+	}
+	parts := strings.Split(rel, "/")
+	// A trailing "**" matches everything inside a directory, but not the directory itself.
+	if last := len(r.pattern) - 1; r.pattern[last] == "**" && matchComponents(r.pattern[:last], parts) {
+		return false
+	}
+	return matchComponents(r.pattern, parts)
+}
+
+// gitignores leaves out of a scan of root whatever the .gitignore files at or below root ignore, along with .git
+// directories, so what's left is roughly what would be committed.  Each .gitignore is read once, when the first
+// entry it could apply to is found.
+type gitignores struct {
+	root  string
+	mu    sync.Mutex                 // Protects rules.
+	rules map[string][]gitignoreRule // The rules of the .gitignore in each directory, if it has one.
+}
+
+func newGitignores(root string) *gitignores {
+	return &gitignores{root: filepath.Clean(root), rules: map[string][]gitignoreRule{}}
+}
+
+// rulesIn returns the rules of the .gitignore in dir, if there is one.
+func (gi *gitignores) rulesIn(dir string) []gitignoreRule {
+	gi.mu.Lock()
+	defer gi.mu.Unlock()
+	if rules, ok := gi.rules[dir]; ok {
+		return rules
+	}
+	var rules []gitignoreRule
+	if data, err := os.ReadFile(filepath.Join(dir, ".gitignore")); err == nil {
+		rules = parseGitignore(string(data))
+	}
+	gi.rules[dir] = rules
+	return rules
+}
+
+// Exclude reports whether the entry at p, described by fi, is ignored.  The .gitignore files of every directory from
+// the root down to the entry's are consulted in turn, and the last pattern matching the entry decides.  It's meant to
+// be used as a Scanner's Exclude function.
+func (gi *gitignores) Exclude(p string, fi os.FileInfo) bool {
+	if fi.IsDir() && fi.Name() == ".git" {
+		return true
+	}
+	dirs := []string{}
+	for d := filepath.Dir(p); ; d = filepath.Dir(d) {
+		dirs = append(dirs, d)
+		if d == gi.root || d == filepath.Dir(d) {
+			break
+		}
+	}
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel := relPath(dirs[i], p)
+		for _, r := range gi.rulesIn(dirs[i]) {
+			if r.negate == ignored && r.match(rel, fi.IsDir()) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
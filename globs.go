@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// globList is a flag.Value collecting repeated glob patterns, for -include and -exclude.
+type globList []string
+
+func (gl *globList) String() string {
+	return strings.Join(*gl, ",")
+}
+
+func (gl *globList) Set(s string) error {
+	if _, err := path.Match(strings.ReplaceAll(s, "**", "*"), ""); err != nil {
+		return err
+	}
+	*gl = append(*gl, strings.TrimPrefix(s, "/"))
+	return nil
+}
+
+// matchGlob reports whether rel, a slash separated path relative to the scan root, matches pattern.  Patterns
+// without a slash match the base name, anywhere in the tree, e.g. "*.bak".  Others match the whole relative path,
+// with "**" matching any number of components, e.g. "tmp/**" or "**/node_modules".
+func matchGlob(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchComponents(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// relPath returns p, a path at or below root, relative to root and with slashes as separators, as patterns are
+// matched against.
+func relPath(root, p string) string {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// matchComponents matches the components of a path against those of a pattern.
+func matchComponents(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchComponents(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// A globFilter leaves entries out of a scan of root by -include and -exclude patterns.  Excluded directories are
+// skipped along with everything below them.  If there are include patterns, only files matching one are kept, but
+// every directory is still walked, as files below it may match.
+type globFilter struct {
+	root             string
+	include, exclude globList
+}
+
+// Exclude reports whether the entry at p, described by fi, should be left out.  It's meant to be used as a
+// Scanner's Exclude function.
+func (gf *globFilter) Exclude(p string, fi os.FileInfo) bool {
+	rel := relPath(gf.root, p)
+	for _, pat := range gf.exclude {
+		if matchGlob(pat, rel) {
+			return true
+		}
+	}
+	if len(gf.include) == 0 || fi.IsDir() {
+		return false
+	}
+	for _, pat := range gf.include {
+		if matchGlob(pat, rel) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// A fakeFile describes a regular file of the given name, for matching without one on disk.
+type fakeFile string
+
+func (f fakeFile) Name() string       { return string(f) }
+func (f fakeFile) Size() int64        { return 0 }
+func (f fakeFile) Mode() os.FileMode  { return 0644 }
+func (f fakeFile) ModTime() time.Time { return time.Time{} }
+func (f fakeFile) IsDir() bool        { return false }
+func (f fakeFile) Sys() interface{}   { return nil }
+
+// writeTree creates files, given as slash separated paths relative to dir, holding their names.
+func writeTree(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	for _, f := range files {
+		p := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// scanFiles scans dir with s, and returns the paths of the files found relative to dir, sorted.
+func scanFiles(t *testing.T, s *Scanner, root *FileRec, dir string) []string {
+	t.Helper()
+	files := []string{}
+	s.Each = func(fr *FileRec) {
+		if !fr.FileInfo.IsDir() {
+			files = append(files, filepath.ToSlash(fr.RelPath(dir)))
+		}
+	}
+	s.Scan(root)
+	sort.Strings(files)
+	return files
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A root ending with a separator, as / does, must match anchored patterns at every level, not just below the top.
+func TestGlobFilterRootWithTrailingSlash(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, "etc/a.conf", "etc/ssh/ssh.conf", "etc/ssh/keys", "etc/x/y/deep.conf", "tmp/b.conf")
+
+	root, err := NewFileRec(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.Name += string(filepath.Separator)
+	filter := &globFilter{root: root.Path(), include: globList{"etc/*/*.conf"}, exclude: globList{"tmp/**"}}
+	got := scanFiles(t, &Scanner{Exclude: filter.Exclude}, root, dir)
+	if want := []string{"etc/ssh/ssh.conf"}; !equalStrings(got, want) {
+		t.Errorf("scan found %v, want %v", got, want)
+	}
+}
+
+func TestGlobFilterSlashRoot(t *testing.T) {
+	filter := &globFilter{root: "/", include: globList{"etc/*/*.conf"}}
+	for p, excluded := range map[string]bool{
+		"/etc/ssh/ssh.conf": false,
+		"/etc/a.conf":       true,
+		"/var/ssh/ssh.conf": true,
+	} {
+		if got := filter.Exclude(p, fakeFile(filepath.Base(p))); got != excluded {
+			t.Errorf("Exclude(%q) = %v, want %v", p, got, excluded)
+		}
+	}
+}
+
+func TestGitignoresRootWithTrailingSlash(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, ".gitignore", "build/out.o", "src/main.go", "src/gen.tmp", "src/.gitignore")
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("/build/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "src", ".gitignore"), []byte("*.tmp\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := NewFileRec(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.Name += string(filepath.Separator)
+	ignores := newGitignores(root.Path())
+	got := scanFiles(t, &Scanner{Exclude: ignores.Exclude}, root, dir)
+	if want := []string{".gitignore", "src/.gitignore", "src/main.go"}; !equalStrings(got, want) {
+		t.Errorf("scan found %v, want %v", got, want)
+	}
+}
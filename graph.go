@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// Graph export formats, chosen by the extension of the -graph file.
+const (
+	graphDOT     = "dot"
+	graphMermaid = "mermaid"
+)
+
+// graphFormat returns the graph format for path, going by its extension.
+func graphFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dot", ".gv":
+		return graphDOT, nil
+	case ".mmd", ".mermaid":
+		return graphMermaid, nil
+	}
+	return "", fmt.Errorf("can't tell the graph format of %v, use a .dot, .gv, .mmd or .mermaid extension", path)
+}
+
+// humanSize formats a byte count with a binary unit, e.g. "1.5 GiB".
+func humanSize(n int64) string {
+	return scaledSize(n, 1024, []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"})
+}
+
+// siSize formats a byte count with a decimal (SI) unit, e.g. "1.6 GB".
+func siSize(n int64) string {
+	return scaledSize(n, 1000, []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"})
+}
+
+// scaledSize formats a byte count in the largest of units, each base times the previous, keeping it at least 1.
+func scaledSize(n int64, base float64, units []string) string {
+	f, i := float64(n), 0
+	for ; f >= base && i < len(units)-1; i++ {
+		f /= base
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d %v", n, units[0])
+	}
+	return fmt.Sprintf("%.1f %v", f, units[i])
+}
+
+// share returns the fraction of the tree's total held by n.
+func (st *sizeTree) share(n *treeNode) float64 {
+	if st.root.Total == 0 {
+		return 0
+	}
+	return float64(n.Total) / float64(st.root.Total)
+}
+
+// nodeName returns the label of n: its path for the root, its base name otherwise.
+func (st *sizeTree) nodeName(n *treeNode, opts *reportOptions) string {
+	if n == st.root {
+		return opts.path(n.Rec)
+	}
+	return filepath.Base(opts.path(n.Rec))
+}
+
+// walkTop calls fn for each node of top, parents before children, along with the node's parent (nil for the root).
+func (st *sizeTree) walkTop(top map[*treeNode]bool, fn func(n, parent *treeNode)) {
+	var walk func(n, parent *treeNode)
+	walk = func(n, parent *treeNode) {
+		fn(n, parent)
+		for _, c := range n.Children {
+			if top[c] {
+				walk(c, n)
+			}
+		}
+	}
+	walk(st.root, nil)
+}
+
+// writeDOT writes the max heaviest directories of st as a Graphviz digraph.  Nodes grow and redden with their share
+// of the total.
+func writeDOT(w io.Writer, st *sizeTree, max int, opts *reportOptions) error {
+	bw := bufio.NewWriter(w)
+	ids := map[*treeNode]int{}
+	fmt.Fprintln(bw, "digraph bff {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=box, style=filled, fontname=\"sans-serif\"];")
+	st.walkTop(st.Top(max), func(n, parent *treeNode) {
+		ids[n] = len(ids)
+		share := st.share(n)
+		fmt.Fprintf(bw, "\tn%d [label=%q, fontsize=%.0f, fillcolor=\"0.0 %.2f 1.0\"];\n", ids[n],
+			st.nodeName(n, opts)+"\n"+humanSize(n.Total), 10+14*math.Sqrt(share), 0.1+0.8*share)
+		if parent != nil {
+			fmt.Fprintf(bw, "\tn%d -> n%d [penwidth=%.1f];\n", ids[parent], ids[n], 1+7*share)
+		}
+	})
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// writeDOTReport writes the heaviest directories of the scan as a Graphviz digraph, like -graph does, for -format dot.
+func writeDOTReport(w io.Writer, res *Results, opts *reportOptions) error {
+	if opts.Tree == nil {
+		return errors.New("the directory tree was dropped to save memory, so there's no graph")
+	}
+	return writeDOT(w, opts.Tree, opts.GraphNodes, opts)
+}
+
+// mermaidLabel escapes s for use in a quoted Mermaid label.
+var mermaidLabel = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+// writeMermaid writes the max heaviest directories of st as a Mermaid flowchart.  Nodes are classed by their share
+// of the total.
+func writeMermaid(w io.Writer, st *sizeTree, max int, opts *reportOptions) error {
+	bw := bufio.NewWriter(w)
+	ids := map[*treeNode]int{}
+	fmt.Fprintln(bw, "flowchart LR")
+	fmt.Fprintln(bw, "\tclassDef huge fill:#e74c3c,color:#fff")
+	fmt.Fprintln(bw, "\tclassDef large fill:#f39c12")
+	fmt.Fprintln(bw, "\tclassDef small fill:#f9e79f")
+	st.walkTop(st.Top(max), func(n, parent *treeNode) {
+		ids[n] = len(ids)
+		class := "small"
+		if share := st.share(n); share >= 0.5 {
+			class = "huge"
+		} else if share >= 0.1 {
+			class = "large"
+		}
+		fmt.Fprintf(bw, "\tn%d[\"%v<br/>%v\"]:::%v\n", ids[n], mermaidLabel.Replace(st.nodeName(n, opts)),
+			humanSize(n.Total), class)
+		if parent != nil {
+			fmt.Fprintf(bw, "\tn%d --> n%d\n", ids[parent], ids[n])
+		}
+	})
+	return bw.Flush()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+// procHolders records which processes hold which files open, so reported entries can be attributed to them.
+type procHolders struct {
+	files map[fileKey][]string // The processes holding each file open, as "name (pid)".
+	dirs  map[string][]string  // The processes holding files directly within each directory open, by path.
+}
+
+func newProcHolders() *procHolders {
+	return &procHolders{files: map[fileKey][]string{}, dirs: map[string][]string{}}
+}
+
+// add records that the process pid, named comm, holds the file identified by key open, found at path.
+func (ph *procHolders) add(key fileKey, path string, pid int, comm string) {
+	proc := fmt.Sprintf("%v (%v)", comm, pid)
+	ph.files[key] = appendUnique(ph.files[key], proc)
+	dir := filepath.Dir(path)
+	ph.dirs[dir] = appendUnique(ph.dirs[dir], proc)
+}
+
+// Of returns the processes holding fr open or, if it's a directory, holding files directly within it open.  It's
+// safe to call on a nil procHolders.
+func (ph *procHolders) Of(fr *FileRec) []string {
+	if ph == nil {
+		return nil
+	}
+	if fr.FileInfo.IsDir() {
+		return ph.dirs[fr.Path()]
+	}
+	if key, _, ok := fileID(fr.FileInfo); ok {
+		return ph.files[key]
+	}
+	return nil
+}
+
+// appendUnique appends s to list unless it's already there.
+func appendUnique(list []string, s string) []string {
+	for _, e := range list {
+		if e == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// attributeProcesses works out which processes hold which files open, logging any trouble.  It returns nil if there's
+// nothing to go on.
+func attributeProcesses() *procHolders {
+	ph, unreadable, err := processHolders()
+	if err != nil {
+		log.Printf("failed to attribute files to processes: %v", err)
+		return nil
+	}
+	if unreadable > 0 {
+		log.Printf("the open files of %v processes couldn't be read; run as root to attribute files to them too",
+			unreadable)
+	}
+	return ph
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+// htmlTreeNodes caps the number of directories drawn in the HTML treemap, so the page stays responsive.
+const htmlTreeNodes = 2000
+
+// An htmlNode is a directory in the treemap of an HTML report.
+type htmlNode struct {
+	Name     string      `json:"n"`
+	Total    int64       `json:"s"`
+	Children []*htmlNode `json:"c,omitempty"`
+}
+
+// An htmlRow is a line of one of the tables of an HTML report.
+type htmlRow struct {
+	Path  string
+	Size  int64
+	Human string
+	Bytes string   // The exact size, for a tooltip.
+	Extra []string // Optional columns after the path, e.g. the top owners of a directory, or annotations.
+}
+
+// An htmlTable is one of the sortable tables of an HTML report.
+type htmlTable struct {
+	Headers []string
+	Rows    []htmlRow
+	Others  string // The row accounting for everything not listed, if any.
+}
+
+// newHTMLTable builds a table of recs, accounting for the rest of count entries totalling total bytes like writeSection
+// does.
+func newHTMLTable(header string, recs []*FileRec, total int64, count int, othersKey string,
+	opts *reportOptions) *htmlTable {
+
+	t := &htmlTable{Headers: strings.Split(header, "\t")}
+	for _, e := range recs {
+		row := htmlRow{Path: opts.path(e), Size: e.Size, Human: humanSize(e.Size), Bytes: opts.msg("html.bytes", e.Size)}
+		if opts.Owners && e.FileInfo.IsDir() {
+			row.Extra = append(row.Extra, formatOwners(e.Owners, opts))
+		}
+		row.Extra = append(row.Extra, opts.trailingCells(e)...)
+		t.Rows = append(t.Rows, row)
+		total -= e.Size
+		count--
+	}
+	if count > 0 {
+		t.Others = humanSize(total) + " " + opts.msg(othersKey, count)
+	}
+	return t
+}
+
+// newHTMLTree converts the heaviest directories of st into htmlNodes, or returns nil if there's no tree.
+func newHTMLTree(st *sizeTree, opts *reportOptions) *htmlNode {
+	if st == nil {
+		return nil
+	}
+	nodes := map[*treeNode]*htmlNode{}
+	st.walkTop(st.Top(htmlTreeNodes), func(n, parent *treeNode) {
+		nodes[n] = &htmlNode{Name: st.nodeName(n, opts), Total: n.Total}
+		if parent != nil {
+			nodes[parent].Children = append(nodes[parent].Children, nodes[n])
+		}
+	})
+	return nodes[st.root]
+}
+
+// writeHTML writes res as a single self-contained HTML page, with a treemap of the directory tree, if there is one,
+// and sortable tables of the largest files and directories.
+func writeHTML(w io.Writer, res *Results, opts *reportOptions) error {
+	// Sizes in the page are always human readable, so the headers and the owners column must be too.
+	human := *opts
+	human.Human = true
+	opts = &human
+
+	data := struct {
+		Root        string
+		Tree        *htmlNode
+		Files, Dirs *htmlTable
+		Summary     string
+		Scanned     string
+		NoTree      string
+		Own         string
+	}{
+		Root:    opts.path(res.Root),
+		Tree:    newHTMLTree(opts.Tree, opts),
+		Summary: opts.msg("html.summary", humanSize(res.Stats.DirBytes), res.Stats.FileCount, res.Stats.DirCount),
+		NoTree:  opts.msg("html.notree"),
+		Own:     opts.msg("html.own"),
+	}
+	if opts.Run != nil {
+		data.Scanned = opts.msg("html.scanned", opts.Run.Hostname, opts.Run.Start.Format("2006-01-02 15:04:05 MST"),
+			opts.Run.Version)
+	}
+	if opts.Files {
+		header := strings.Join(append([]string{opts.header("files.header")}, opts.trailingHeaders()...), "\t")
+		data.Files = newHTMLTable(header, res.Files, res.Stats.FileBytes, res.Stats.FileCount,
+			"others.files", opts)
+	}
+	if opts.Dirs {
+		header := opts.header("dirs.header")
+		if opts.Owners {
+			header += "\t" + opts.header("owners.header")
+		}
+		header = strings.Join(append([]string{header}, opts.trailingHeaders()...), "\t")
+		data.Dirs = newHTMLTable(header, res.Dirs, res.Stats.DirBytes, res.Stats.DirCount, "others.dirs", opts)
+	}
+	return htmlReport.Execute(w, data)
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>bff: {{.Root}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; color: #222; }
+h1 { font-size: 1.4em; }
+.meta { color: #666; font-size: 0.9em; }
+#crumbs { margin: 0.5em 0; }
+#crumbs a { cursor: pointer; color: #2166ac; }
+#treemap { position: relative; width: 100%; height: 60vh; border: 1px solid #999; overflow: hidden; }
+#treemap div { position: absolute; box-sizing: border-box; border: 1px solid #fff; overflow: hidden;
+	font-size: 11px; padding: 2px; cursor: pointer; white-space: nowrap; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { padding: 2px 10px; text-align: left; }
+th { cursor: pointer; border-bottom: 1px solid #999; }
+td.size { text-align: right; font-variant-numeric: tabular-nums; }
+tr:nth-child(even) td { background: #f4f4f4; }
+</style>
+</head>
+<body>
+<h1>{{.Root}}</h1>
+<p class="meta">{{.Summary}}{{.Scanned}}</p>
+{{if .Tree}}
+<div id="crumbs"></div>
+<div id="treemap"></div>
+{{else}}
+<p class="meta">{{.NoTree}}</p>
+{{end}}
+{{- define "table"}}
+<table class="sortable">
+<thead><tr>{{range $i, $h := .Headers}}<th data-col="{{$i}}">{{$h}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr><td class="size" data-sort="{{.Size}}" title="{{.Bytes}}">{{.Human}}</td><td>{{.Path}}</td>
+{{- range .Extra}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+{{- with .Others}}
+<tfoot><tr><td colspan="{{len $.Headers}}">{{.}}</td></tr></tfoot>
+{{- end}}
+</table>
+{{- end}}
+{{with .Files}}{{template "table" .}}{{end}}
+{{with .Dirs}}{{template "table" .}}{{end}}
+<script>
+"use strict";
+function human(n) {
+	const units = ["B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"];
+	let i = 0;
+	for (; n >= 1024 && i < units.length - 1; i++) n /= 1024;
+	return i == 0 ? n + " B" : n.toFixed(1) + " " + units[i];
+}
+
+// Tables sort by the clicked column; clicking again reverses the order.
+for (const table of document.querySelectorAll("table.sortable")) {
+	for (const th of table.querySelectorAll("th")) {
+		th.addEventListener("click", () => {
+			const col = +th.dataset.col, tbody = table.tBodies[0];
+			const desc = th.dataset.dir != "desc";
+			th.dataset.dir = desc ? "desc" : "asc";
+			const key = td => td.dataset.sort !== undefined ? +td.dataset.sort : td.textContent;
+			const rows = Array.from(tbody.rows);
+			rows.sort((a, b) => {
+				const x = key(a.cells[col] || a.cells[0]), y = key(b.cells[col] || b.cells[0]);
+				return (x < y ? -1 : x > y ? 1 : 0) * (desc ? -1 : 1);
+			});
+			rows.forEach(r => tbody.appendChild(r));
+		});
+	}
+}
+
+const tree = {{.Tree}}, ownTitle = {{.Own}};
+if (tree) {
+	const map = document.getElementById("treemap"), crumbs = document.getElementById("crumbs");
+	const parents = new Map();
+	(function link(n) { for (const c of n.c || []) { parents.set(c, n); link(c); } })(tree);
+
+	// Squarified layout: lay children out in rows along the short side, keeping their aspect ratios close to 1.
+	function squarify(items, x, y, w, h, total, out) {
+		while (items.length > 0) {
+			const short = Math.min(w, h), scale = w * h / total;
+			let row = [], rowSum = 0, worst = Infinity;
+			for (const it of items) {
+				const sum = rowSum + it.s, side = sum * scale / short;
+				const r = Math.max(...row.concat(it).map(c => Math.max(side * side / (c.s * scale),
+					c.s * scale / (side * side))));
+				if (r > worst) break;
+				row.push(it); rowSum = sum; worst = r;
+			}
+			const side = rowSum * scale / short;
+			let off = 0;
+			for (const it of row) {
+				const len = it.s * scale / side;
+				out.push(w >= h ? [it, x, y + off, side, len] : [it, x + off, y, len, side]);
+				off += len;
+			}
+			if (w >= h) { x += side; w -= side; } else { y += side; h -= side; }
+			total -= rowSum;
+			items = items.slice(row.length);
+		}
+	}
+
+	let current = tree;
+	function draw(node) {
+		current = node;
+		map.textContent = "";
+		crumbs.textContent = "";
+		const path = [];
+		for (let n = node; n; n = parents.get(n)) path.unshift(n);
+		path.forEach((n, i) => {
+			const a = document.createElement("a");
+			a.textContent = n.n;
+			a.addEventListener("click", () => draw(n));
+			crumbs.append(i > 0 ? " / " : "", a);
+		});
+		crumbs.append(" (" + human(node.s) + ")");
+
+		// The directory's own files get a box of their own, next to its subdirectories.
+		const children = (node.c || []).filter(c => c.s > 0);
+		const own = node.s - children.reduce((a, c) => a + c.s, 0);
+		const items = own > 0 ? children.concat({n: "", s: own, own: true}) : children;
+		items.sort((a, b) => b.s - a.s);
+		if (node.s == 0 || items.length == 0) return;
+
+		const out = [];
+		squarify(items, 0, 0, map.clientWidth, map.clientHeight, node.s, out);
+		out.forEach(([it, x, y, w, h], i) => {
+			const d = document.createElement("div");
+			Object.assign(d.style, {left: x + "px", top: y + "px", width: w + "px", height: h + "px",
+				background: it.own ? "#ddd" : "hsl(" + (i * 47 % 360) + ", 55%, " + (it.c ? 65 : 78) + "%)"});
+			d.textContent = it.own ? human(it.s) : it.n + " " + human(it.s);
+			d.title = (it.own ? ownTitle.replace("%v", node.n) : it.n) + ": " + human(it.s);
+			if (!it.own && it.c) d.addEventListener("click", () => draw(it));
+			map.appendChild(d);
+		});
+	}
+	draw(tree);
+	window.addEventListener("resize", () => draw(current));
+}
+</script>
+</body>
+</html>
+`))
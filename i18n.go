@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A translation maps message keys to format strings in one language.
+type translation map[string]string
+
+// translations holds the human facing report strings for each supported language.  English is the fallback for
+// unknown languages and for keys missing from a translation.
+var translations = map[string]translation{
+	"en": {
+		"files.header":        "File size (bytes)\tFile path",
+		"dirs.header":         "Dir size (bytes)\tDir path",
+		"owners.header":       "Top owners (bytes)",
+		"files.header.human":  "File size\tFile path",
+		"dirs.header.human":   "Dir size\tDir path",
+		"owners.header.human": "Top owners",
+		"special.header":      "Special file type\tSpecial file path",
+		"type.header":         "Type",
+		"mtime.header":        "Modified",
+		"owner.header":        "Owner",
+		"group.header":        "Group",
+		"holders.header":      "Held open by",
+		"annotation.header":   "Annotation",
+		"others.files":        "everything else, in %v files",
+		"others.dirs":         "everything else, in %v dirs",
+		"others.entries":      "everything else, in %v entries",
+		"depth.header":        "Level %v: size (bytes)\tPath",
+		"depth.header.human":  "Level %v: size\tPath",
+		"band.under":          "under %v",
+		"band.over":           "over %v",
+		"special.fifo":        "fifo",
+		"special.socket":      "socket",
+		"special.chardev":     "char device",
+		"special.blockdev":    "block device",
+		"system.header":       "System file size (bytes)\tSystem file path\tNotes",
+		"system.header.human": "System file size\tSystem file path\tNotes",
+		"system.swap":         "swap space in use: turn it off (swapoff, or in the virtual memory settings) before removing or resizing it",
+		"system.loop":         "image behind loop device %v: unmount it and detach it with losetup -d before removing it",
+		"system.hibernation":  "hibernation image: turn hibernation off to remove it",
+		"summary":             "scanned %v files and %v dirs, %v in total, in %v, with %v errors",
+		"digits.group":        ",",
+		"html.summary":        "%v in %v files and %v directories",
+		"html.scanned":        ", scanned on %v at %v by bff %v",
+		"html.notree":         "The directory tree wasn't kept, so there's no treemap.",
+		"html.own":            "files directly in %v",
+		"html.bytes":          "%v bytes",
+	},
+	"de": {
+		"files.header":        "Dateigröße (Bytes)\tDateipfad",
+		"dirs.header":         "Verzeichnisgröße (Bytes)\tVerzeichnispfad",
+		"owners.header":       "Größte Eigentümer (Bytes)",
+		"files.header.human":  "Dateigröße\tDateipfad",
+		"dirs.header.human":   "Verzeichnisgröße\tVerzeichnispfad",
+		"owners.header.human": "Größte Eigentümer",
+		"special.header":      "Typ der Spezialdatei\tPfad der Spezialdatei",
+		"type.header":         "Typ",
+		"mtime.header":        "Geändert",
+		"owner.header":        "Eigentümer",
+		"group.header":        "Gruppe",
+		"holders.header":      "Geöffnet von",
+		"annotation.header":   "Anmerkung",
+		"others.files":        "alles andere, Dateien: %v",
+		"others.dirs":         "alles andere, Verzeichnisse: %v",
+		"others.entries":      "alles andere, Einträge: %v",
+		"depth.header":        "Ebene %v: Größe (Bytes)\tPfad",
+		"depth.header.human":  "Ebene %v: Größe\tPfad",
+		"band.under":          "unter %v",
+		"band.over":           "über %v",
+		"special.fifo":        "FIFO",
+		"special.socket":      "Socket",
+		"special.chardev":     "Zeichengerät",
+		"special.blockdev":    "Blockgerät",
+		"system.header":       "Systemdateigröße (Bytes)\tSystemdateipfad\tHinweise",
+		"system.header.human": "Systemdateigröße\tSystemdateipfad\tHinweise",
+		"system.swap":         "Auslagerungsspeicher in Benutzung: vor dem Entfernen oder Verkleinern abschalten (swapoff oder in den Einstellungen für virtuellen Speicher)",
+		"system.loop":         "Abbild hinter dem Loop-Gerät %v: vor dem Entfernen aushängen und mit losetup -d lösen",
+		"system.hibernation":  "Ruhezustandsabbild: verschwindet, wenn der Ruhezustand abgeschaltet wird",
+		"summary":             "%v Dateien und %v Verzeichnisse gescannt, insgesamt %v, in %v, Fehler: %v",
+		"digits.group":        ".",
+		"html.summary":        "%v in %v Dateien und %v Verzeichnissen",
+		"html.scanned":        ", gescannt auf %v am %v von bff %v",
+		"html.notree":         "Der Verzeichnisbaum wurde nicht behalten, daher gibt es keine Treemap.",
+		"html.own":            "Dateien direkt in %v",
+		"html.bytes":          "%v Bytes",
+	},
+	"fr": {
+		"files.header":        "Taille du fichier (octets)\tChemin du fichier",
+		"dirs.header":         "Taille du répertoire (octets)\tChemin du répertoire",
+		"owners.header":       "Principaux propriétaires (octets)",
+		"files.header.human":  "Taille du fichier\tChemin du fichier",
+		"dirs.header.human":   "Taille du répertoire\tChemin du répertoire",
+		"owners.header.human": "Principaux propriétaires",
+		"special.header":      "Type de fichier spécial\tChemin du fichier spécial",
+		"type.header":         "Type",
+		"mtime.header":        "Modifié",
+		"owner.header":        "Propriétaire",
+		"group.header":        "Groupe",
+		"holders.header":      "Ouvert par",
+		"annotation.header":   "Annotation",
+		"others.files":        "tout le reste, fichiers : %v",
+		"others.dirs":         "tout le reste, répertoires : %v",
+		"others.entries":      "tout le reste, entrées : %v",
+		"depth.header":        "Niveau %v : taille (octets)\tChemin",
+		"depth.header.human":  "Niveau %v : taille\tChemin",
+		"band.under":          "moins de %v",
+		"band.over":           "plus de %v",
+		"special.fifo":        "FIFO",
+		"special.socket":      "socket",
+		"special.chardev":     "périphérique caractère",
+		"special.blockdev":    "périphérique bloc",
+		"system.header":       "Taille du fichier système (octets)\tChemin du fichier système\tRemarques",
+		"system.header.human": "Taille du fichier système\tChemin du fichier système\tRemarques",
+		"system.swap":         "espace d'échange utilisé : le désactiver (swapoff, ou dans les réglages de mémoire virtuelle) avant de le supprimer ou de le redimensionner",
+		"system.loop":         "image derrière le périphérique loop %v : le démonter et le détacher avec losetup -d avant de la supprimer",
+		"system.hibernation":  "image d'hibernation : désactiver l'hibernation pour la supprimer",
+		"summary":             "%v fichiers et %v répertoires analysés, %v au total, en %v, erreurs : %v",
+		"digits.group":        "\u202f",
+		"html.summary":        "%v dans %v fichiers et %v répertoires",
+		"html.scanned":        ", analysé sur %v le %v par bff %v",
+		"html.notree":         "L'arborescence n'a pas été conservée, il n'y a donc pas de treemap.",
+		"html.own":            "fichiers directement dans %v",
+		"html.bytes":          "%v octets",
+	},
+	"es": {
+		"files.header":        "Tamaño del archivo (bytes)\tRuta del archivo",
+		"dirs.header":         "Tamaño del directorio (bytes)\tRuta del directorio",
+		"owners.header":       "Principales propietarios (bytes)",
+		"files.header.human":  "Tamaño del archivo\tRuta del archivo",
+		"dirs.header.human":   "Tamaño del directorio\tRuta del directorio",
+		"owners.header.human": "Principales propietarios",
+		"special.header":      "Tipo de archivo especial\tRuta del archivo especial",
+		"type.header":         "Tipo",
+		"mtime.header":        "Modificado",
+		"owner.header":        "Propietario",
+		"group.header":        "Grupo",
+		"holders.header":      "Abierto por",
+		"annotation.header":   "Anotación",
+		"others.files":        "todo lo demás, archivos: %v",
+		"others.dirs":         "todo lo demás, directorios: %v",
+		"others.entries":      "todo lo demás, entradas: %v",
+		"depth.header":        "Nivel %v: tamaño (bytes)\tRuta",
+		"depth.header.human":  "Nivel %v: tamaño\tRuta",
+		"band.under":          "menos de %v",
+		"band.over":           "más de %v",
+		"special.fifo":        "FIFO",
+		"special.socket":      "socket",
+		"special.chardev":     "dispositivo de caracteres",
+		"special.blockdev":    "dispositivo de bloques",
+		"system.header":       "Tamaño del archivo de sistema (bytes)\tRuta del archivo de sistema\tNotas",
+		"system.header.human": "Tamaño del archivo de sistema\tRuta del archivo de sistema\tNotas",
+		"system.swap":         "espacio de intercambio en uso: desactívelo (swapoff, o en la configuración de memoria virtual) antes de borrarlo o redimensionarlo",
+		"system.loop":         "imagen del dispositivo loop %v: desmóntelo y desconéctelo con losetup -d antes de borrarla",
+		"system.hibernation":  "imagen de hibernación: desactive la hibernación para eliminarla",
+		"summary":             "%v archivos y %v directorios analizados, %v en total, en %v, errores: %v",
+		"digits.group":        ".",
+		"html.summary":        "%v en %v archivos y %v directorios",
+		"html.scanned":        ", analizado en %v el %v por bff %v",
+		"html.notree":         "No se conservó el árbol de directorios, así que no hay treemap.",
+		"html.own":            "archivos directamente en %v",
+		"html.bytes":          "%v bytes",
+	},
+	"pl": {
+		"files.header":        "Rozmiar pliku (bajty)\tŚcieżka pliku",
+		"dirs.header":         "Rozmiar katalogu (bajty)\tŚcieżka katalogu",
+		"owners.header":       "Główni właściciele (bajty)",
+		"files.header.human":  "Rozmiar pliku\tŚcieżka pliku",
+		"dirs.header.human":   "Rozmiar katalogu\tŚcieżka katalogu",
+		"owners.header.human": "Główni właściciele",
+		"special.header":      "Typ pliku specjalnego\tŚcieżka pliku specjalnego",
+		"type.header":         "Typ",
+		"mtime.header":        "Zmodyfikowano",
+		"owner.header":        "Właściciel",
+		"group.header":        "Grupa",
+		"holders.header":      "Otwarte przez",
+		"annotation.header":   "Adnotacja",
+		"others.files":        "cała reszta, plików: %v",
+		"others.dirs":         "cała reszta, katalogów: %v",
+		"others.entries":      "cała reszta, wpisów: %v",
+		"depth.header":        "Poziom %v: rozmiar (bajty)\tŚcieżka",
+		"depth.header.human":  "Poziom %v: rozmiar\tŚcieżka",
+		"band.under":          "poniżej %v",
+		"band.over":           "powyżej %v",
+		"special.fifo":        "FIFO",
+		"special.socket":      "gniazdo",
+		"special.chardev":     "urządzenie znakowe",
+		"special.blockdev":    "urządzenie blokowe",
+		"system.header":       "Rozmiar pliku systemowego (bajty)\tŚcieżka pliku systemowego\tUwagi",
+		"system.header.human": "Rozmiar pliku systemowego\tŚcieżka pliku systemowego\tUwagi",
+		"system.swap":         "używana przestrzeń wymiany: wyłącz ją (swapoff lub w ustawieniach pamięci wirtualnej) przed usunięciem lub zmianą rozmiaru",
+		"system.loop":         "obraz urządzenia loop %v: odmontuj je i odłącz poleceniem losetup -d przed usunięciem",
+		"system.hibernation":  "obraz hibernacji: wyłącz hibernację, aby go usunąć",
+		"summary":             "przeskanowano plików: %v, katalogów: %v, łącznie %v, w %v, błędów: %v",
+		"digits.group":        "\u00a0",
+		"html.summary":        "%v, plików: %v, katalogów: %v",
+		"html.scanned":        ", przeskanowano na %v o %v przez bff %v",
+		"html.notree":         "Drzewo katalogów nie zostało zachowane, więc nie ma mapy drzewa.",
+		"html.own":            "pliki bezpośrednio w %v",
+		"html.bytes":          "bajtów: %v",
+	},
+}
+
+// detectLang returns the language selected by the usual locale environment variables, e.g. "de" for
+// LANG=de_DE.UTF-8, or "en" if none of them name a supported language.
+func detectLang() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			return normalizeLang(v)
+		}
+	}
+	return "en"
+}
+
+// normalizeLang reduces a locale name such as "pl_PL.UTF-8" to a supported language code, defaulting to "en".
+func normalizeLang(locale string) string {
+	if lang, ok := langCode(locale); ok {
+		return lang
+	}
+	return "en"
+}
+
+// langCode reduces a locale name such as "pl_PL.UTF-8" to its language code, and reports whether that's supported.
+func langCode(locale string) (string, bool) {
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "_.@-"); i >= 0 {
+		lang = lang[:i]
+	}
+	_, ok := translations[lang]
+	return lang, ok
+}
+
+// msg returns the message for key in lang, formatted with args.
+func msg(lang, key string, args ...interface{}) string {
+	format, ok := translations[lang][key]
+	if !ok {
+		format = translations["en"][key]
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
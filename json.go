@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A jsonOwner is the JSON representation of an OwnerShare.
+type jsonOwner struct {
+	User  string `json:"user"`
+	UID   uint32 `json:"uid"`
+	Bytes int64  `json:"bytes"`
+}
+
+// A jsonRec is the JSON representation of a FileRec.
+type jsonRec struct {
+	Path        string      `json:"path"`
+	Size        int64       `json:"size"`
+	Type        string      `json:"type"`
+	Mtime       time.Time   `json:"mtime"`
+	NewestMtime *time.Time  `json:"newest_mtime,omitempty"` // Directories only.
+	FutureDated bool        `json:"future_dated,omitempty"` // The mtime is in the future.
+	Depth       int         `json:"depth"`
+	Parent      string      `json:"parent"`
+	RelPath     string      `json:"rel_path"`
+	Owner       string      `json:"owner,omitempty"` // With -long.
+	Group       string      `json:"group,omitempty"` // With -long.
+	Owners      []jsonOwner `json:"owners,omitempty"`
+	HeldBy      []string    `json:"held_by,omitempty"` // Processes holding the entry, or files directly in it, open.
+	Annotation  string      `json:"annotation,omitempty"`
+}
+
+// A jsonSystemFile is the JSON representation of a systemFile.
+type jsonSystemFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Kind   string `json:"kind"`
+	Device string `json:"device,omitempty"`
+}
+
+// jsonStats is the JSON representation of Stats.
+type jsonStats struct {
+	Files       int     `json:"files"`
+	Dirs        int     `json:"dirs"`
+	Special     int     `json:"special"`
+	FileBytes   int64   `json:"file_bytes"`
+	DirBytes    int64   `json:"dir_bytes"`
+	FutureDated int     `json:"future_dated"`
+	Coverage    float64 `json:"coverage"` // Fraction of the directories found which were read.
+}
+
+// A jsonReport is the JSON representation of Results.  Sections which weren't asked for are null.
+type jsonReport struct {
+	Root    string           `json:"root"`
+	Files   []jsonRec        `json:"files"`
+	Dirs    []jsonRec        `json:"dirs"`
+	Special []jsonRec        `json:"special,omitempty"`
+	System  []jsonSystemFile `json:"system_files,omitempty"`
+	Totals  jsonStats        `json:"totals"`
+}
+
+// A jsonEnvelope wraps a jsonReport with a description of the run which produced it.
+type jsonEnvelope struct {
+	SchemaVersion int `json:"schema_version"`
+	*runInfo
+	Results *jsonReport `json:"results"`
+}
+
+// fileType returns the type of the file described by fi: "file", "dir", "symlink", or the kind of special file.
+func fileType(fi os.FileInfo) string {
+	switch {
+	case fi.IsDir():
+		return "dir"
+	case fi.Mode()&os.ModeSymlink != 0:
+		return "symlink"
+	case isSpecial(fi):
+		return specialKind(fi)
+	}
+	return "file"
+}
+
+// newJSONRec converts fr, found in a scan of root, for output.
+func newJSONRec(fr *FileRec, root *FileRec, opts *reportOptions) jsonRec {
+	jr := jsonRec{
+		Path:        opts.path(fr),
+		Size:        fr.Size,
+		Type:        fileType(fr.FileInfo),
+		Mtime:       fr.FileInfo.ModTime(),
+		Depth:       fr.Depth,
+		Parent:      opts.mapPath(fr.Parent()),
+		RelPath:     opts.Redact.Redact(filepath.ToSlash(fr.RelPath(root.Path()))),
+		FutureDated: fr.Future,
+		HeldBy:      opts.Holders.Of(fr),
+		Annotation:  opts.annotation(fr),
+	}
+	if uid, gid, ok := fileOwner(fr.FileInfo); ok && opts.Long {
+		jr.Owner, jr.Group = ownerName(uid, opts.Numeric), groupName(gid, opts.Numeric)
+	}
+	if fr.FileInfo.IsDir() {
+		newest := fr.Newest
+		jr.NewestMtime = &newest
+	}
+	for _, sh := range fr.Owners {
+		jr.Owners = append(jr.Owners, jsonOwner{User: ownerName(sh.UID, opts.Numeric), UID: sh.UID, Bytes: sh.Bytes})
+	}
+	return jr
+}
+
+// newJSONRecs converts recs for output.
+func newJSONRecs(recs []*FileRec, root *FileRec, opts *reportOptions) []jsonRec {
+	jrs := make([]jsonRec, 0, len(recs))
+	for _, fr := range recs {
+		jrs = append(jrs, newJSONRec(fr, root, opts))
+	}
+	return jrs
+}
+
+// newJSONReport converts res for output.
+func newJSONReport(res *Results, opts *reportOptions) *jsonReport {
+	jr := &jsonReport{
+		Root: opts.path(res.Root),
+		Totals: jsonStats{
+			Files:       res.Stats.FileCount,
+			Dirs:        res.Stats.DirCount,
+			Special:     res.Stats.Special,
+			FileBytes:   res.Stats.FileBytes,
+			DirBytes:    res.Stats.DirBytes,
+			FutureDated: res.Stats.Future,
+			Coverage:    res.Stats.Coverage(),
+		},
+	}
+	if opts.Files {
+		jr.Files = newJSONRecs(res.Files, res.Root, opts)
+	}
+	if opts.Dirs {
+		jr.Dirs = newJSONRecs(res.Dirs, res.Root, opts)
+	}
+	if opts.Special {
+		jr.Special = newJSONRecs(res.Special, res.Root, opts)
+	}
+	for _, sf := range opts.System {
+		jr.System = append(jr.System, jsonSystemFile{Path: opts.mapPath(sf.Path), Size: sf.Size, Kind: sf.Kind,
+			Device: sf.Device})
+	}
+	return jr
+}
+
+// writeJSON writes res to w as an indented JSON document, in an envelope describing the run.
+func writeJSON(w io.Writer, res *Results, opts *reportOptions) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jsonEnvelope{SchemaVersion: jsonSchemaVersion, runInfo: opts.Run, Results: newJSONReport(res, opts)})
+}
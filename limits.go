@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// sizeSuffixes maps the unit suffixes accepted by parseSize to their multipliers.  Units are powers of 1024, as
+// that's what people mean when they say a disk or a VM has "8G".
+var sizeSuffixes = []struct {
+	suffix string
+	mult   float64
+}{
+	{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize parses a byte count such as "4096", "512M" or "1.5G".
+func parseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	mult := float64(1)
+	for _, e := range sizeSuffixes {
+		if strings.HasSuffix(str, e.suffix) {
+			str = strings.TrimSpace(strings.TrimSuffix(str, e.suffix))
+			mult = e.mult
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(str, 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * mult), nil
+}
+
+// A sizeFlag is a flag.Value holding a byte count parsed by parseSize.
+type sizeFlag int64
+
+func (sf *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*sf), 10)
+}
+
+func (sf *sizeFlag) Set(s string) error {
+	n, err := parseSize(s)
+	if err != nil {
+		return err
+	}
+	*sf = sizeFlag(n)
+	return nil
+}
+
+// A fileLimiter bounds the number of directories held open at once.  A nil fileLimiter imposes no bound.
+type fileLimiter chan struct{}
+
+func (fl fileLimiter) acquire() {
+	if fl != nil {
+		fl <- struct{}{}
+	}
+}
+
+func (fl fileLimiter) release() {
+	if fl != nil {
+		<-fl
+	}
+}
+
+// openFiles bounds the directories held open by readDir, which lists every directory read, from NewFileRec and
+// from the scan alike.  It's set up from -max-open-files in main, and imposes no bound unless that's given.
+var openFiles fileLimiter
+
+// A memGuard watches the memory held by the process against a soft limit.  Once usage gets close to the limit the
+// guard starts shedding: features which retain every scanned entry are expected to check Shedding and stop growing,
+// so the scan finishes with a degraded report rather than being OOM-killed halfway through.
+type memGuard struct {
+	limit    int64 // Soft limit in bytes, 0 if there is none.
+	shedding int32 // Set to 1, atomically, once usage crossed the shedding threshold.
+}
+
+// shedRatio is the fraction of the memory limit at which a memGuard starts shedding.
+const shedRatio = 0.9
+
+// newMemGuard sets up a memGuard for limit bytes and hands the same limit to the Go runtime, so the garbage collector
+// works harder as it's approached.  If limit is 0, the limit set via GOMEMLIMIT (if any) is used instead.
+func newMemGuard(limit int64) *memGuard {
+	if limit > 0 {
+		debug.SetMemoryLimit(limit)
+	} else {
+		limit = debug.SetMemoryLimit(-1)
+		if limit == math.MaxInt64 {
+			limit = 0
+		}
+	}
+	return &memGuard{limit: limit}
+}
+
+// Shedding reports whether memory usage is close enough to the limit that optional retention should stop.
+func (mg *memGuard) Shedding() bool {
+	return mg != nil && atomic.LoadInt32(&mg.shedding) == 1
+}
+
+// usage returns the memory currently obtained from the OS and not yet returned to it.
+func (mg *memGuard) usage() int64 {
+	ms := runtime.MemStats{}
+	runtime.ReadMemStats(&ms)
+	return int64(ms.Sys - ms.HeapReleased)
+}
+
+// Watch polls memory usage every interval until the guard starts shedding.  It's meant to run as a go routine.
+func (mg *memGuard) Watch(interval time.Duration) {
+	if mg.limit == 0 {
+		return
+	}
+	threshold := int64(float64(mg.limit) * shedRatio)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if used := mg.usage(); used >= threshold {
+			atomic.StoreInt32(&mg.shedding, 1)
+			log.Printf("memory usage %v bytes is close to the %v byte limit, shedding full listings", used, mg.limit)
+			debug.FreeOSMemory()
+			return
+		}
+	}
+}
+
+// memory is the memGuard for the running scan.  It's set up from -max-memory in main.
+var memory *memGuard
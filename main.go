@@ -5,20 +5,32 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
-	"text/tabwriter"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // A FileRec wraps os.FileInfo information for a file.  Path and Size are provided as os.FileInfo.Name() provides
-// only the base name, and os.FileInfo.Size() does not take into account directory contents.
+// only the base name, and os.FileInfo.Size() does not take into account directory contents.  Rather than its full
+// path, a FileRec holds its name and a pointer to its directory, so that each directory's path is stored just once
+// however many entries are kept below it.
 type FileRec struct {
-	Path     string        // The full path of a file.
+	Name     string        // The base name of the file, or the full path for the root of a scan.
+	Dir      *FileRec      // The directory containing the file, nil for the root of a scan.
 	Size     int64         // Size of the file.  If file is a directory, it's the sum of the sizes of it's contents.
 	FileInfo os.FileInfo   // Interface describing the file.
 	Contents []os.FileInfo // Slice containing directory contents.
+	Newest   time.Time     // Newest mtime of the file or, for a directory, of it and anything below it.
+	Future   bool          // The mtime is in the future, so it's left out of Newest.
+	Owners   []OwnerShare  // For a directory, the users owning most of its contents, if the scan tracks owners.
+	Depth    int           // Number of levels below the scan root, which is at depth 0.
+	Count    int           // For a directory, the number of entries directly in it.
 }
 
 // Implement sort.Interface (Len, Swap and Less), as  we want to sort our collection of FileRec entries by their size.
@@ -32,14 +44,105 @@ func (bs bySize) Swap(i, j int) {
 	bs[i], bs[j] = bs[j], bs[i]
 }
 
-// Less is actually reversed, as we want to sort from largest to smallest FileRec's.
+// Less is actually reversed, as we want to sort from largest to smallest FileRec's.  Equal sizes are ordered by
+// path, so that results don't depend on the order in which go routines happened to deliver them.
 func (bs bySize) Less(i, j int) bool {
-	return bs[i].Size > bs[j].Size
+	if bs[i].Size != bs[j].Size {
+		return bs[i].Size > bs[j].Size
+	}
+	return comparePaths(bs[i], bs[j]) < 0
+}
+
+// Path returns the full path of the file.
+func (b FileRec) Path() string {
+	if b.Dir == nil {
+		return b.Name
+	}
+	names := []string{b.Name}
+	dir := b.Dir
+	for ; dir.Dir != nil; dir = dir.Dir {
+		names = append(names, dir.Name)
+	}
+	sb := strings.Builder{}
+	sb.WriteString(dir.Name)
+	for i := len(names) - 1; i >= 0; i-- {
+		if !strings.HasSuffix(sb.String(), "/") {
+			sb.WriteByte('/')
+		}
+		sb.WriteString(names[i])
+	}
+	return sb.String()
+}
+
+// comparePaths compares the paths of a and b like strings.Compare would, but without building them.
+func comparePaths(a, b *FileRec) int {
+	if a == b {
+		return 0
+	}
+	// Climb to the closest common ancestor, keeping track of its children on the way to a and b.
+	origA, origB := a, b
+	var childA, childB *FileRec
+	for a.Depth > b.Depth && a.Dir != nil {
+		childA, a = a, a.Dir
+	}
+	for b.Depth > a.Depth && b.Dir != nil {
+		childB, b = b, b.Dir
+	}
+	for a != b && a.Dir != nil && b.Dir != nil {
+		childA, a = a, a.Dir
+		childB, b = b, b.Dir
+	}
+	switch {
+	case a != b:
+		// Not from the same scan.
+		return strings.Compare(origA.Path(), origB.Path())
+	case childA == nil:
+		// a is an ancestor of b, so its path is a prefix of b's.
+		return -1
+	case childB == nil:
+		return 1
+	}
+
+	// The paths diverge at the names of childA and childB, which differ.  If one name is a prefix of the other, the
+	// shorter path continues with a separator, if anything.
+	na, nb := childA.Name, childB.Name
+	for i := 0; i < len(na) && i < len(nb); i++ {
+		if na[i] != nb[i] {
+			return int(na[i]) - int(nb[i])
+		}
+	}
+	if len(na) < len(nb) {
+		if childA == origA {
+			return -1
+		}
+		return int('/') - int(nb[len(na)])
+	}
+	if childB == origB {
+		return 1
+	}
+	return int(na[len(nb)]) - int('/')
 }
 
 // Implement Stringer interface.
 func (b FileRec) String() string {
-	return fmt.Sprintf("size: %v bytes -> %v", b.Size, b.Path)
+	return fmt.Sprintf("size: %v bytes -> %v", b.Size, b.Path())
+}
+
+// Parent returns the path of the directory containing the file.
+func (b FileRec) Parent() string {
+	if b.Dir != nil {
+		return b.Dir.Path()
+	}
+	return filepath.Dir(b.Name)
+}
+
+// RelPath returns the path of the file relative to root, or its full path if it isn't below root.
+func (b FileRec) RelPath(root string) string {
+	rel, err := filepath.Rel(root, b.Path())
+	if err != nil {
+		return b.Path()
+	}
+	return rel
 }
 
 // NewFileRec produces a ready-to-use FileRec pointer, including a full Path and Size.  If the FileRec represents
@@ -62,13 +165,7 @@ func NewFileRec(p string) (*FileRec, error) {
 
 	// If the path p reprents a directory, store the directory contents and sum the sizes of the contents.
 	if pFileInfo.IsDir() {
-		dir, err := os.Open(absPath)
-		defer dir.Close()
-		if err != nil {
-			return f, err
-		}
-
-		dirContents, err := dir.Readdir(0)
+		dirContents, err := readDir(absPath)
 		if err != nil {
 			return f, err
 		}
@@ -84,57 +181,432 @@ func NewFileRec(p string) (*FileRec, error) {
 		f.Size = pFileInfo.Size()
 	}
 
-	f.Path = absPath
+	f.Name = absPath
 	f.FileInfo = pFileInfo
 
 	return f, nil
 }
 
-// InsertSorted appends a FileRec pointer to a slice, and returns a trimmed slice up to max elements.
-func InsertSorted(frSlice []*FileRec, fr *FileRec, max int) []*FileRec {
-	frSlice = append(frSlice, fr)
-	sort.Sort(bySize(frSlice))
-	if len(frSlice) < max {
-		max = len(frSlice)
+// readDir lists the directory at path.
+func readDir(path string) ([]os.FileInfo, error) {
+	openFiles.acquire()
+	defer openFiles.release()
+
+	dir, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
-	return frSlice[:max]
+	defer dir.Close()
+	return dir.Readdir(0)
+}
+
+// A Scanner walks a directory tree and ranks the largest files and directories within it.  A Scanner may be reused,
+// but only for one Scan at a time.
+type Scanner struct {
+	FileLimit  int           // Maximum number of files to keep.  0 means no limit.
+	DirLimit   int           // Maximum number of directories to keep.  0 means no limit.
+	NoFiles    bool          // Don't rank files at all.
+	NoDirs     bool          // Don't rank directories at all.
+	Symlinks   string        // How symlinks are sized, one of the symlinks* policies.  Defaults to symlinksLink.
+	Special    bool          // Collect FIFOs, sockets and device files into Results.Special.
+	Owners     bool          // Work out the top owners of each directory's contents.
+	Workers    int           // Maximum number of go routines walking at once.  0 means one per root directory entry.
+	DebugStats time.Duration // If non-zero, log runtime stats at this interval while scanning.
+	Budget     time.Duration // If non-zero, walk breadth first, and stop reading directories after this long.
+
+	// Exclude, if set, is called for every entry found, and the entry is left out of the scan if it returns true.
+	Exclude func(path string, fi os.FileInfo) bool
+
+	// Each, if set, is called for every entry scanned apart from the root, as it's scanned.  It's always called from
+	// the go routine running Scan.
+	Each func(fr *FileRec)
+
+	visited  *visitedSet   // Objects seen so far in the current scan.
+	root     string        // Path of the root of the current scan, with symlinks resolved.
+	start    time.Time     // When the current scan started.
+	unwalked int64         // Directories left unread for lack of time, updated atomically.
+	errors   int64         // Entries which couldn't be read, updated atomically.
+	sending  int64         // Walkers waiting for Scan to take an entry off them, updated atomically.
+	workers  chan struct{} // Walker slots for the current scan, nil if unbounded.
+	deniedMu sync.Mutex    // Protects denied.
+	denied   []string      // Paths which couldn't be read for lack of permission.
+}
+
+// deny records that path couldn't be read for lack of permission.
+func (s *Scanner) deny(path string) {
+	s.deniedMu.Lock()
+	defer s.deniedMu.Unlock()
+	s.denied = append(s.denied, path)
+}
+
+// Stats summarises everything a Scan visited, not just what made it into the rankings.
+type Stats struct {
+	FileCount int   // Number of files scanned.
+	DirCount  int   // Number of directories scanned, including the root.
+	FileBytes int64 // Sum of the sizes of all files scanned.
+	Special   int   // Number of FIFOs, sockets and device files scanned.  They're not counted as files.
+	DirBytes  int64 // Sum of the sizes of all directories scanned.
+	Future    int   // Number of entries with mtimes in the future.
+	Unwalked  int   // Number of directories left unread because the time budget ran out.
+	Errors    int   // Number of entries which couldn't be read, and were left out.
+}
+
+// Coverage returns the fraction of the directories found which were read, 1 unless the time budget ran out.
+func (st Stats) Coverage() float64 {
+	return float64(st.DirCount) / float64(st.DirCount+st.Unwalked)
+}
+
+// Entries returns the number of entries scanned, not counting the root.
+func (st Stats) Entries() int {
+	return st.FileCount + st.DirCount + st.Special - 1
 }
 
-// Walk recursively walks paths, starting at basePath, and pumps FileRec pointers into the FileRec pointer channel.
-func Walk(fi os.FileInfo, basePath string, fileRecCh chan *FileRec) {
-	fr, err := NewFileRec(basePath + "/" + fi.Name())
+// Results holds the outcome of a Scan.
+type Results struct {
+	Root    *FileRec   // The directory the scan started from.
+	Files   []*FileRec // The largest files, sorted from largest to smallest.
+	Dirs    []*FileRec // The largest directories, sorted from largest to smallest.
+	Special []*FileRec // FIFOs, sockets and device files, if the Scanner was asked to collect them.
+	Denied  []string   // Paths which couldn't be read for lack of permission, sorted.
+	Stats   Stats
+}
+
+// account works out what each entry of directory fr contributes to the scan, and sets fr.Size to the total.
+// Entries already visited through another path are dropped, so every object is counted exactly once no matter how
+// many ways it can be reached, symlinks are sized according to the symlink policy, and special files count as zero.
+func (s *Scanner) account(fr *FileRec) {
+	dirPath := fr.Path()
+	contents := fr.Contents[:0]
+	size := int64(0)
+	var bytesByUID map[uint32]int64
+	if s.Owners {
+		bytesByUID = map[uint32]int64{}
+	}
+	for _, e := range fr.Contents {
+		if s.Exclude != nil && s.Exclude(filepath.Join(dirPath, e.Name()), e) {
+			continue
+		}
+		if e.Mode()&os.ModeSymlink != 0 {
+			if e = s.resolveSymlink(filepath.Join(dirPath, e.Name()), e); e == nil {
+				continue
+			}
+		} else if isSpecial(e) {
+			e = sizedInfo{FileInfo: e, size: 0}
+		} else if !s.visited.Visit(e) {
+			continue
+		}
+		contents = append(contents, e)
+		size += e.Size()
+		if uid, _, ok := fileOwner(e); ok && bytesByUID != nil {
+			bytesByUID[uid] += e.Size()
+		}
+	}
+	fr.Contents = contents
+	fr.Count = len(contents)
+	fr.Size = size
+	if len(bytesByUID) > 0 {
+		fr.Owners = topOwners(bytesByUID)
+	}
+}
+
+// Walk recursively walks paths, starting at fi in directory dir, and pumps FileRec pointers into the FileRec pointer
+// channel.  basePath is the path of dir, passed along so it isn't rebuilt for every entry.  Directories are sent once
+// everything below them has been walked, and Walk returns the Newest mtime it saw.
+func (s *Scanner) Walk(fi os.FileInfo, dir *FileRec, basePath string, depth int, fileRecCh chan *FileRec) time.Time {
+	// The listing already has everything there is to know about fi, so it isn't stat'ed again.  The sizes of
+	// symlinks and special files depend on policy, and were settled when the directory was accounted.
+	fr := &FileRec{Name: fi.Name(), Dir: dir, FileInfo: fi, Size: fi.Size(), Depth: depth}
+	s.setMtime(fr)
+
+	// If fr is a directory itself, recursively walk it.  The listing isn't needed once walked, so let it go rather
+	// than have it pinned for as long as fr is held in a result slice.
+	if fi.IsDir() {
+		path := filepath.Join(basePath, fi.Name())
+		if !s.readContents(fr, path) {
+			return time.Time{}
+		}
+		if t := s.walkContents(fr, path, depth+1, fileRecCh); t.After(fr.Newest) {
+			fr.Newest = t
+		}
+		fr.Contents = nil
+	}
+	atomic.AddInt64(&s.sending, 1)
+	fileRecCh <- fr
+	atomic.AddInt64(&s.sending, -1)
+
+	return fr.Newest
+}
+
+// readContents lists directory fr, found at path, into fr.Contents and accounts for its entries.  It reports whether
+// the directory could be read; if not, the failure is logged and counted, and fr should be left out of the scan.
+func (s *Scanner) readContents(fr *FileRec, path string) bool {
+	contents, err := readDir(path)
 	if err != nil {
-		log.Printf("failed to create FileRec: %v, skipping", err)
-		return
+		log.Printf("failed to read directory: %v, skipping", err)
+		atomic.AddInt64(&s.errors, 1)
+		if os.IsPermission(err) {
+			s.deny(path)
+		}
+		return false
+	}
+	fr.Contents = contents
+	s.account(fr)
+	return true
+}
+
+// GoWalk is a wrapper around Walk.  It's spooled up as a go routine and signals when it's done by sending the newest
+// mtime seen.  When the number of workers is bounded, GoWalk waits for a free slot before walking.
+func (s *Scanner) GoWalk(fi os.FileInfo, root *FileRec, fileRecCh chan *FileRec, doneCh chan time.Time) {
+	if s.workers != nil {
+		s.workers <- struct{}{}
+		defer func() { <-s.workers }()
+	}
+	doneCh <- s.Walk(fi, root, root.Name, 1, fileRecCh)
+}
+
+// Scan walks the contents of root and returns the largest files and directories found.  root itself is ranked
+// among the directories.
+func (s *Scanner) Scan(root *FileRec) *Results {
+	s.visited = newVisitedSet(s.Symlinks == symlinksTarget)
+	s.root = root.Path()
+	if resolved, err := filepath.EvalSymlinks(s.root); err == nil {
+		s.root = resolved
+	}
+	s.denied = nil
+	s.workers = nil
+	if s.Workers > 0 {
+		s.workers = make(chan struct{}, s.Workers)
+	}
+	s.start = time.Now()
+	s.unwalked, s.errors, s.sending = 0, 0, 0
+	s.visited.Visit(root.FileInfo)
+	s.setMtime(root)
+	s.account(root)
+
+	// Start our rankings off with the root search path.
+	res := &Results{Root: root, Stats: Stats{DirCount: 1, DirBytes: root.Size}}
+	if root.Future {
+		res.Stats.Future++
+	}
+	files, dirs := newRanking(s.FileLimit), newRanking(s.DirLimit)
+	if !s.NoDirs {
+		dirs.Offer(root)
+	}
+
+	fileRecCh := make(chan *FileRec) // Receives FileRec pointers from GoWalk go routines.
+	doneCh := make(chan time.Time)   // Receives notification that a given go routine has finished walking it's path.
+
+	if s.DebugStats > 0 {
+		go debugStats(s.DebugStats, &s.sending)
+	}
+
+	// Traverse contents of root and spool up a go routine to walk each entry.  With a time budget, a single go
+	// routine deepens the scan a level at a time instead.
+	walkers := len(root.Contents)
+	if s.Budget > 0 {
+		walkers = 1
+		go func() { doneCh <- s.deepen(root, fileRecCh) }()
 	} else {
-		fileRecCh <- fr
+		for _, e := range root.Contents {
+			go s.GoWalk(e, root, fileRecCh, doneCh)
+		}
 	}
 
-	// If fr is a directory itself, recursively walk it.
-	if fr.FileInfo.IsDir() {
-		for _, e := range fr.Contents {
-			Walk(e, fr.Path, fileRecCh)
+	// While we have outstanding go routines, continue reading from fileRecCh and offer FileRec pointers to the
+	// designated rankings.
+	for i := 0; i < walkers; {
+		select {
+		case fr := <-fileRecCh:
+			if s.Each != nil {
+				s.Each(fr)
+			}
+			if fr.Future {
+				res.Stats.Future++
+			}
+			if isSpecial(fr.FileInfo) {
+				res.Stats.Special++
+				if s.Special {
+					res.Special = append(res.Special, fr)
+				}
+			} else if !fr.FileInfo.IsDir() {
+				res.Stats.FileCount++
+				res.Stats.FileBytes += fr.Size
+				if !s.NoFiles {
+					files.Offer(fr)
+				}
+			} else {
+				res.Stats.DirCount++
+				res.Stats.DirBytes += fr.Size
+				if !s.NoDirs {
+					dirs.Offer(fr)
+				}
+			}
+		case t := <-doneCh:
+			if t.After(root.Newest) {
+				root.Newest = t
+			}
+			i++
 		}
 	}
-}
+	root.Contents = nil
 
-// GoWalk is a wrapper around Walk.  It's spooled up as a go routine and signals when it's done.
-func GoWalk(fi os.FileInfo, basePath string, fileRecCh chan *FileRec, doneCh chan int) {
-	Walk(fi, basePath, fileRecCh)
-	doneCh <- 1
+	res.Files, res.Dirs = files.Sorted(), dirs.Sorted()
+	sort.Slice(res.Special, func(i, j int) bool { return comparePaths(res.Special[i], res.Special[j]) < 0 })
+	res.Denied = s.denied
+	sort.Strings(res.Denied)
+	res.Stats.Unwalked, res.Stats.Errors = int(s.unwalked), int(s.errors)
+	return res
 }
 
 func main() {
+	// Subcommands take over the whole command line.
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		runBench(os.Args[2:])
+		return
+	}
+
 	// Override default flag usage message.
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] directory\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s bench [options]\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 
 	// Limit results option.  Defaults to 10.
-	resultLimit := flag.Int("limit", 10, "limit number of results to display")
+	resultLimit := flag.Int("limit", 10, "limit number of results to display (0 means no limit)")
+	fileLimit := flag.Int("limit-files", -1, "limit number of files to display (defaults to -limit)")
+	dirLimit := flag.Int("limit-dirs", -1, "limit number of directories to display (defaults to -limit)")
+	filesOnly := flag.Bool("files-only", false, "only report files")
+	dirsOnly := flag.Bool("dirs-only", false, "only report directories")
+	symlinks := flag.String("count-symlinks", symlinksLink, "count symlinks as their own size (link), their "+
+		"target's size if it's outside the scanned directory (target), or not at all (skip)")
+	listSpecial := flag.Bool("list-special", false, "list FIFOs, sockets and device files in a separate section")
+	owners := flag.Bool("owners", false, "show the top owners of each directory's contents")
+	numericIDs := flag.Bool("numeric-ids", false, "show user and group IDs instead of resolving names")
+	annotationsFile := flag.String("annotations", "", "label entries with the team, service or ticket they belong "+
+		"to, from a file of path prefixes and labels, e.g. '/srv/kafka data-platform team' (one per line)")
+	includes, excludes := globList{}, globList{}
+	flag.Var(&includes, "include", "only scan files matching this glob, e.g. '*.log' for base names anywhere or "+
+		"'var/**/*.log' for paths below the scanned directory (repeatable)")
+	flag.Var(&excludes, "exclude", "leave out entries matching this glob, and everything below matching directories, "+
+		"e.g. '*.bak' or 'tmp/**' (repeatable)")
+	plugins := pluginList{}
+	flag.Var(&plugins, "plugin", "run this command over the reported entries, sent as ndjson on its standard input; "+
+		"it may answer with JSON lines such as {\"path\": ..., \"annotation\": ..., \"hide\": true} (repeatable)")
+	respectGitignore := flag.Bool("respect-gitignore", false, "leave out what .gitignore files at or below the "+
+		"scanned directory ignore, and .git directories")
+	prefixes := prefixMap{}
+	flag.Var(&prefixes, "map-prefix", "report paths below from as below to, e.g. /host/root=/ (repeatable)")
+	relative := flag.Bool("relative", false, "show paths relative to the scanned directory")
+	redact := flag.String("redact", "", "obfuscate path components in the report: hash (all of them) or basename "+
+		"(all but the base name)")
+	lang := flag.String("lang", "", "language of the report: en, de, fr, es or pl (defaults to $LANG)")
+	plain := flag.Bool("plain", false, "plain output: tab separated, no alignment or decoration, one record per line")
+	percent := flag.Bool("percent", false, "show each size as a percentage of the scanned directory's total size, "+
+		"which is everything scanned")
+	long := flag.Bool("long", false, "show the modification time, owner and group of each entry")
+	types := flag.Bool("types", false, "show the type of each entry: file, dir, symlink, or the kind of special file")
+	group := flag.Bool("group-digits", false, "group the digits of byte counts in thousands, e.g. 1,234,567, as is "+
+		"usual in the report language")
+	human := flag.Bool("H", false, "show sizes with binary units, e.g. 1.5 GiB, instead of bytes")
+	si := flag.Bool("si", false, "with -H, use decimal units, e.g. 1.6 GB")
+	color := flag.String("color", colorAuto, "color the report: auto (if writing to a terminal and NO_COLOR isn't "+
+		"set), always or never")
+	colorThresholds := flag.String("color-thresholds", "1G,100M", "with -color, sizes from the first threshold are "+
+		"red, from the second yellow, and green below that")
+	copyN := flag.Int("copy", 0, "copy the paths of the top n files (or directories, with -dirs-only) to the clipboard")
+	revealTop := flag.Bool("reveal", false, "open the directory containing the top file (or directory, with -dirs-only) "+
+		"in the file manager")
+	skipTCC := flag.Bool("skip-tcc-protected", false, "on macOS, don't scan locations protected by TCC")
+	sandbox := flag.Bool("sandbox", false, "on Linux, use Landlock to restrict bff to reading below the scanned directory")
+	reexec := flag.Bool("sudo-reexec", false, "rescan top-level directories which turn out to be unreadable with sudo")
+	flamegraph := flag.String("flamegraph", "", "write folded stacks of file sizes, for flamegraph.pl, speedscope or "+
+		"d3-flamegraph, to this file")
+	graph := flag.String("graph", "", "write the heaviest directories as a Graphviz (.dot, .gv) or Mermaid (.mmd, "+
+		".mermaid) graph to this file")
+	graphNodes := flag.Int("graph-nodes", 30, "number of directories in the -graph and -format dot output")
+	format := flag.String("format", "text", "output format: text, json, csv, yaml, md, html, dot (a "+
+		"Graphviz graph of the heaviest directories), ncdu (every entry, for ncdu -f), folded (flame graph stacks of "+
+		"every file) or ndjson (every entry)")
+	streamNDJSON := flag.Bool("stream", false, "with -format ndjson, write each entry as soon as it's scanned, in no "+
+		"particular order, rather than all of them in the same order on every run once the scan is over")
+	tmpl := flag.String("template", "", "write each entry with this Go text/template, e.g. '{{.Size}} {{.Path}}', "+
+		"instead of a -format")
+	print0 := flag.Bool("print0", false, "write only the paths of the reported entries, separated by NUL bytes, for "+
+		"xargs -0")
+	duMode := flag.String("du", "", "write every entry (a) or just the total (s) like du -ab or du -sb, instead of a "+
+		"report")
+	sortKey := flag.String("sort", sortSize, "order of the reported entries: size (largest first), mtime (oldest "+
+		"first), path or count (most entries first); the largest entries are reported either way")
+	reverse := flag.Bool("reverse", false, "reverse the -sort order")
+	output := flag.String("o", "", "write the report to this file instead of standard output; it's replaced "+
+		"atomically, so it's never seen half-written")
+	dfTolerance := flag.Float64("df-check", 0, "if scanning a whole filesystem, warn when the space found differs "+
+		"from what df reports as used by more than this percentage (0 means no check)")
+	deletedMin := sizeFlag(0)
+	flag.Var(&deletedMin, "deleted", "on Linux, list deleted files of at least this size, e.g. 100M, which processes "+
+		"still hold open on the scanned filesystem (0 means don't look)")
+	byDepth := flag.Int("by-depth", 0, "report the n largest entries at each depth below the scanned directory, "+
+		"directories by their total size, instead of the largest overall")
+	attribute := flag.Bool("attribute-process", false, "on Linux, show which processes hold the reported files, or "+
+		"files directly in the reported directories, open")
+	buckets := flag.String("buckets", "", "group results into size bands, e.g. 1G,10G,100G")
+
+	// Self-imposed resource limits, so a scan degrades rather than getting killed on a small VM.
+	maxMemory := sizeFlag(0)
+	flag.Var(&maxMemory, "max-memory", "soft memory limit for the scan, e.g. 512M (defaults to GOMEMLIMIT)")
+	maxOpenFiles := flag.Int("max-open-files", 0, "maximum number of directories held open at once (0 means no limit)")
+
+	// Diagnostics for profiling long scans.
+	pprofAddr := flag.String("pprof", "", "serve pprof endpoints on this address, e.g. :6060")
+	tracePath := flag.String("trace", "", "write a runtime execution trace to this file")
+	debugInterval := flag.Duration("debug-stats", 0, "log goroutine, backlog and allocation stats at this interval")
+
+	// Walk concurrency.  Use 'bff bench' to find a good value for a given machine.
+	workers := flag.Int("workers", 0, "maximum number of concurrent walkers (0 means one per top-level entry)")
+	budget := flag.Duration("budget", 0, "scan breadth first, a level at a time, for at most this long, e.g. 5m, "+
+		"and report what was found with the share of directories covered")
+	printSchema := flag.Bool("schema", false, "print the JSON Schema of the -format (json, yaml or ndjson), and exit")
+	schemaVersion := flag.Int("schema-version", 0, "fail unless the machine readable formats are written with this "+
+		"schema version, for tooling pinned to one")
 	flag.Parse()
 
+	if *schemaVersion != 0 {
+		if err := checkSchemaVersion(*schemaVersion); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *printSchema {
+		// There's no text schema, so -schema on its own describes the json format.
+		f := *format
+		if f == "text" {
+			f = "json"
+		}
+		schema, err := schemaFor(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(schema)
+		return
+	}
+
+	if *pprofAddr != "" {
+		go servePprof(*pprofAddr)
+	}
+	if *tracePath != "" {
+		stopTrace, err := startTrace(*tracePath)
+		if err != nil {
+			log.Fatalf("failed to start trace: %v", err)
+		}
+		defer stopTrace()
+	}
+
+	memory = newMemGuard(int64(maxMemory))
+	go memory.Watch(250 * time.Millisecond)
+	if *maxOpenFiles > 0 {
+		openFiles = make(fileLimiter, *maxOpenFiles)
+	}
+
 	// We only care about the first positional argument as we'll only process one path at a time.
 	if flag.NArg() < 1 {
 		log.Fatal("directory path not provided")
@@ -147,45 +619,425 @@ func main() {
 		log.Fatalf("failure in %v: %v", pathStr, err)
 	}
 	if !rootFileRec.FileInfo.IsDir() {
-		log.Fatalf("%v is not a directory", rootFileRec.Path)
+		log.Fatalf("%v is not a directory", rootFileRec.Path())
 	}
 
-	// Start our slices off with the root search path.
-	bigFiles := []*FileRec{}
-	bigDirs := []*FileRec{rootFileRec}
-
-	fileRecCh := make(chan *FileRec) // Receives FileRec pointers from GoWalk go routines.
-	doneCh := make(chan int)         // Receives notification that a given go routine has finished walking it's path.
+	writeReport, ok := reportWriters[*format]
+	if !ok && *format != formatNDJSON && *format != formatFolded && *format != formatNCDU {
+		log.Fatalf("unknown output format %q", *format)
+	}
+	if *streamNDJSON && *format != formatNDJSON {
+		log.Fatal("-stream only applies to -format ndjson")
+	}
+	if *duMode != "" {
+		if err := checkDUMode(*duMode); err != nil {
+			log.Fatal(err)
+		}
+		if *format != "text" || *tmpl != "" || *print0 {
+			log.Fatal("-du can't be combined with -format, -template or -print0")
+		}
+	}
+	if *output != "" && *sandbox {
+		log.Fatal("-o can't be combined with -sandbox, which forbids renaming the report into place; redirect " +
+			"standard output instead")
+	}
+	if *copyN > 0 && *sandbox {
+		log.Fatal("-copy can't be combined with -sandbox, which forbids running the clipboard tools")
+	}
+	if *revealTop && *sandbox {
+		log.Fatal("-reveal can't be combined with -sandbox, which forbids running the file manager")
+	}
+	if *reexec && *sandbox {
+		log.Fatal("-sudo-reexec can't be combined with -sandbox, which forbids running other programs")
+	}
+	if len(plugins) > 0 {
+		if *sandbox {
+			log.Fatal("-plugin can't be combined with -sandbox, which forbids running other programs")
+		}
+		if *duMode != "" || *format == formatNDJSON || *format == formatFolded || *format == formatNCDU {
+			log.Fatal("-plugin applies to reports of the largest entries, so it can't be combined with -du or a " +
+				"format listing every entry")
+		}
+	}
+	if *print0 {
+		if *format != "text" || *tmpl != "" {
+			log.Fatal("-print0 can't be combined with -format or -template")
+		}
+		writeReport = writePrint0
+	}
+	if *byDepth > 0 {
+		if *format != "text" || *tmpl != "" || *print0 || *duMode != "" {
+			log.Fatal("-by-depth can't be combined with -format, -template, -print0 or -du")
+		}
+	}
+	if *tmpl != "" {
+		if *format != "text" {
+			log.Fatal("-template and -format are mutually exclusive")
+		}
+		if writeReport, err = newTemplateWriter(*tmpl); err != nil {
+			log.Fatalf("invalid -template: %v", err)
+		}
+	}
+	if *filesOnly && *dirsOnly {
+		log.Fatal("-files-only and -dirs-only are mutually exclusive")
+	}
+	if err := checkSymlinkPolicy(*symlinks); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkSortKey(*sortKey); err != nil {
+		log.Fatal(err)
+	}
+	if *buckets != "" && (*sortKey != sortSize || *reverse) {
+		log.Fatal("-buckets needs entries in size order, so it can't be combined with -sort or -reverse")
+	}
+	if *resultLimit < 0 {
+		log.Fatal("-limit can't be negative; use 0 for no limit")
+	}
+	if *fileLimit < -1 || *dirLimit < -1 {
+		log.Fatal("-limit-files and -limit-dirs can't be negative; use 0 for no limit, or leave them out to use -limit")
+	}
+	if *fileLimit < 0 {
+		*fileLimit = *resultLimit
+	}
+	if *dirLimit < 0 {
+		*dirLimit = *resultLimit
+	}
 
-	// Traverse contents of rootFileRec and spool up a go routine to walk each entry.
-	for _, e := range rootFileRec.Contents {
-		go GoWalk(e, rootFileRec.Path, fileRecCh, doneCh)
+	// When running unprivileged, tell the user up front what we won't be able to see.
+	unreadable := []string{}
+	if os.Geteuid() > 0 {
+		unreadable = probeUnreadable(rootFileRec)
+		if len(unreadable) > 0 {
+			log.Printf("%v top-level directories can't be read and will be left out: %v", len(unreadable),
+				strings.Join(unreadable, ", "))
+			if !*reexec {
+				log.Printf("pass -sudo-reexec to rescan them with sudo afterwards")
+			}
+		}
 	}
 
-	// While we have outstanding go routines, continue reading from fileRecCh and insert FileRec pointers to the
-	// designated slices.
-	for i := 0; i < len(rootFileRec.Contents); {
-		select {
-		case fr := <-fileRecCh:
-			if !fr.FileInfo.IsDir() {
-				bigFiles = InsertSorted(bigFiles, fr, *resultLimit)
-			} else {
-				bigDirs = InsertSorted(bigDirs, fr, *resultLimit)
+	scanner := &Scanner{
+		FileLimit:  *fileLimit,
+		DirLimit:   *dirLimit,
+		NoFiles:    *dirsOnly,
+		NoDirs:     *filesOnly,
+		Symlinks:   *symlinks,
+		Special:    *listSpecial,
+		Owners:     *owners,
+		Workers:    *workers,
+		DebugStats: *debugInterval,
+		Budget:     *budget,
+	}
+	filter := &globFilter{root: rootFileRec.Path(), include: includes, exclude: excludes}
+	// Only protected locations strictly below the root are left out; if the root itself is protected, leaving it out
+	// would leave nothing to report.
+	protected := []string{}
+	if *skipTCC {
+		below := strings.TrimSuffix(rootFileRec.Path(), "/") + "/" // So that a root of / works too.
+		for _, p := range tccProtectedPaths() {
+			if underAny(rootFileRec.Path(), []string{p}) {
+				log.Fatalf("-skip-tcc-protected would leave out everything, as %v is protected by TCC; grant Full "+
+					"Disk Access instead, or scan a directory above it", p)
 			}
-		case _ = <-doneCh:
-			i++
+			if strings.HasPrefix(p, below) {
+				protected = append(protected, p)
+			}
+		}
+	}
+	var ignores *gitignores
+	if *respectGitignore {
+		ignores = newGitignores(rootFileRec.Path())
+	}
+	if len(protected) > 0 || len(includes) > 0 || len(excludes) > 0 || ignores != nil {
+		scanner.Exclude = func(path string, fi os.FileInfo) bool {
+			return underAny(path, protected) || filter.Exclude(path, fi) || (ignores != nil && ignores.Exclude(path, fi))
+		}
+	}
+
+	opts := &reportOptions{
+		Files:      !*dirsOnly,
+		Dirs:       !*filesOnly,
+		Special:    *listSpecial,
+		Owners:     *owners,
+		Numeric:    *numericIDs,
+		Prefixes:   prefixes,
+		Lang:       detectLang(),
+		Plain:      *plain,
+		Percent:    *percent,
+		Types:      *types,
+		Long:       *long,
+		Group:      *group,
+		Human:      *human || *si,
+		SI:         *si,
+		GraphNodes: *graphNodes,
+	}
+	if *annotationsFile != "" {
+		if opts.Notes, err = loadAnnotations(*annotationsFile); err != nil {
+			log.Fatalf("failed to load annotations: %v", err)
+		}
+	}
+	if !*plain {
+		if opts.Color, err = useColor(*color); err != nil {
+			log.Fatalf("invalid -color: %v", err)
+		}
+		// Auto color goes by standard output, which isn't where the report goes with -o.
+		if *output != "" && *color == colorAuto {
+			opts.Color = false
+		}
+		if opts.RedSize, opts.YellowSize, err = parseColorThresholds(*colorThresholds); err != nil {
+			log.Fatalf("invalid -color-thresholds: %v", err)
+		}
+	}
+	if *lang != "" {
+		var ok bool
+		if opts.Lang, ok = langCode(*lang); !ok {
+			log.Fatalf("unsupported -lang %q: the supported languages are en, de, fr, es and pl", *lang)
+		}
+	}
+	if opts.Redact, err = newRedactor(*redact); err != nil {
+		log.Fatalf("invalid -redact: %v", err)
+	}
+	if opts.Run, err = newRunInfo(opts.path(rootFileRec), flag.CommandLine, opts.Redact); err != nil {
+		log.Fatalf("failed to describe run: %v", err)
+	}
+	// The run still names the scanned directory in full; only the entries below it are relative.
+	if *relative {
+		opts.Relative = rootFileRec.Path()
+	}
+	if *buckets != "" {
+		opts.Buckets, err = parseBuckets(*buckets)
+		if err != nil {
+			log.Fatalf("invalid -buckets: %v", err)
+		}
+	}
+
+	var folded *foldedWriter
+	if *flamegraph != "" {
+		f, err := os.Create(*flamegraph)
+		if err != nil {
+			log.Fatalf("failed to create flame graph: %v", err)
+		}
+		defer f.Close()
+		folded = newFoldedWriter(f, rootFileRec)
+	}
+
+	var tree *sizeTree
+	var graphFile *os.File
+	graphFmt := ""
+	if *graph != "" {
+		if graphFmt, err = graphFormat(*graph); err != nil {
+			log.Fatal(err)
+		}
+		if graphFile, err = os.Create(*graph); err != nil {
+			log.Fatalf("failed to create graph: %v", err)
+		}
+		defer graphFile.Close()
+	}
+	if graphFile != nil || *format == "html" || *format == "dot" || *byDepth > 0 {
+		tree = newSizeTree(rootFileRec)
+	}
+
+	var export *ncduExport
+	if *format == formatNCDU {
+		export = newNCDUExport()
+		writeReport = export.Write
+	}
+
+	var depths *depthRanking
+	if *byDepth > 0 {
+		depths = newDepthRanking(*byDepth)
+		writeReport = newDepthWriter(depths)
+	}
+
+	var out io.Writer = os.Stdout
+	var report *atomicFile
+	if *output != "" {
+		if report, err = createAtomic(*output); err != nil {
+			log.Fatalf("failed to create report: %v", err)
 		}
+		out = report
 	}
 
-	tabW := &tabwriter.Writer{}
-	tabW.Init(os.Stdout, 0, 8, 2, ' ', 0)
-	fmt.Fprintln(tabW, "File size (bytes)\tFile path")
-	for _, e := range bigFiles {
-		fmt.Fprintf(tabW, "%v\t%v\n", e.Size, e.Path)
+	var stream *ndjsonWriter
+	if *format == formatNDJSON {
+		stream = newNDJSONWriter(out, rootFileRec, opts)
 	}
-	fmt.Fprintln(tabW, "Dir size (bytes)\tDir path")
-	for _, e := range bigDirs {
-		fmt.Fprintf(tabW, "%v\t%v\n", e.Size, e.Path)
+
+	var foldedStream *foldedWriter
+	if *format == formatFolded {
+		foldedStream = newFoldedWriter(out, rootFileRec)
+	}
+
+	var df *dfCheck
+	if *dfTolerance > 0 {
+		if df, err = newDFCheck(rootFileRec); err != nil {
+			log.Printf("skipping -df-check: %v", err)
+		}
+	}
+
+	var du *duWriter
+	if *duMode != "" {
+		du = newDUWriter(out, *duMode, opts)
+	}
+
+	// Unless ndjson is to be streamed, outputs listing every entry are written once the scan is over, in an order which
+	// doesn't depend on the scheduling of the walking go routines.
+	var order *entryOrder
+	if (stream != nil && !*streamNDJSON) || foldedStream != nil || folded != nil || (du != nil && *duMode == duAll) {
+		order = newEntryOrder()
+	}
+	writeOrdered := func(fr *FileRec) {
+		if stream != nil {
+			stream.Write(fr)
+		}
+		if foldedStream != nil {
+			foldedStream.Write(fr)
+		}
+		if folded != nil {
+			folded.Write(fr)
+		}
+		if du != nil {
+			du.Write(fr)
+		}
+	}
+
+	scanner.Each = func(fr *FileRec) {
+		if stream != nil && *streamNDJSON {
+			stream.Write(fr)
+		}
+		if order != nil {
+			order.Add(fr)
+		} else if du != nil {
+			du.Write(fr)
+		}
+		if df != nil {
+			df.Add(fr)
+		}
+		if tree != nil {
+			tree.Add(fr)
+		}
+		if depths != nil {
+			depths.Add(fr)
+		}
+		if export != nil {
+			export.Add(fr)
+		}
+	}
+
+	// Look for system files now, while /proc is still readable.
+	opts.System = findSystemFiles(rootFileRec.Path())
+
+	// Every file we write to is open by now, so lock ourselves down if asked to.  User and group names need to
+	// stay resolvable.
+	if *sandbox {
+		readable := []string{rootFileRec.Path(), "/etc/passwd", "/etc/group", "/etc/nsswitch.conf"}
+		if deletedMin > 0 || *attribute {
+			readable = append(readable, "/proc")
+		}
+		if err := sandboxReadOnly(readable); err != nil {
+			log.Fatalf("failed to sandbox: %v", err)
+		}
+	}
+
+	results := scanner.Scan(rootFileRec)
+	opts.Run.End = time.Now()
+	if order != nil {
+		order.Each(rootFileRec, writeOrdered)
+	}
+	if folded != nil {
+		if err := folded.Flush(); err != nil {
+			log.Printf("failed to write flame graph: %v", err)
+		}
+	}
+
+	if tree != nil {
+		if tree.Build() {
+			opts.Tree = tree
+		} else {
+			log.Printf("not enough memory to keep the directory tree, skipping the graph and treemap")
+		}
+	}
+	if *attribute {
+		opts.Holders = attributeProcesses()
+	}
+	for _, p := range plugins {
+		if err := runPlugin(p, results, opts); err != nil {
+			log.Printf("plugin %q failed: %v", p, err)
+		}
+	}
+	if graphFile != nil && opts.Tree != nil {
+		write := writeDOT
+		if graphFmt == graphMermaid {
+			write = writeMermaid
+		}
+		if err := write(graphFile, opts.Tree, *graphNodes, opts); err != nil {
+			log.Printf("failed to write graph: %v", err)
+		}
+	}
+
+	switch {
+	case du != nil:
+		du.Write(rootFileRec)
+		err = du.Flush()
+	case stream != nil:
+		stream.Write(rootFileRec)
+		err = stream.Err()
+	case foldedStream != nil:
+		err = foldedStream.Flush()
+	default:
+		err = writeReport(out, results.sortedBy(*sortKey, *reverse), opts)
+	}
+	if report != nil {
+		if err == nil {
+			err = report.Commit()
+		} else {
+			report.Abort()
+		}
+	}
+	if err != nil {
+		log.Fatalf("failed to write report: %v", err)
+	}
+
+	if *reexec && len(unreadable) > 0 {
+		sudoReexec(unreadable, os.Args[1:len(os.Args)-flag.NArg()])
+	}
+
+	if results.Stats.Unwalked > 0 {
+		log.Printf("the time budget ran out with %v directories left unread; the report covers %.1f%% of the "+
+			"directories found, and is a best effort", results.Stats.Unwalked, 100*results.Stats.Coverage())
+	}
+	if results.Stats.Future > 0 {
+		log.Printf("%v entries have mtimes in the future, from a bad clock or a restored backup; they're left out "+
+			"of the newest mtimes, and sort last by mtime", results.Stats.Future)
+	}
+
+	if df != nil {
+		df.Report(results, *dfTolerance, scanner.Exclude != nil)
+	}
+	if deletedMin > 0 {
+		reportDeletedFiles(rootFileRec, int64(deletedMin))
+	}
+
+	// On macOS, permission errors below some locations mean TCC blocked us, and the totals are misleadingly small.
+	if blocked := tccBlocked(results.Denied); len(blocked) > 0 {
+		log.Printf("macOS privacy protection (TCC) blocked access to %v locations, e.g. %v; the totals above leave "+
+			"them out", len(blocked), blocked[0])
+		log.Printf("to fix this, %v", tccRemedy)
+	}
+
+	// Desktop actions apply to the top files, or the top directories if that's all that was reported.
+	topRecs := results.Files
+	if *dirsOnly {
+		topRecs = results.Dirs
+	}
+	if *copyN > 0 {
+		if err := copyPaths(topRecs, *copyN); err != nil {
+			log.Printf("failed to copy to clipboard: %v", err)
+		}
+	}
+	if *revealTop && len(topRecs) > 0 {
+		if err := reveal(topRecs[0].Path()); err != nil {
+			log.Printf("failed to reveal %v: %v", topRecs[0].Path(), err)
+		}
 	}
-	tabW.Flush()
 }
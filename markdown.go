@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// mdCell escapes s for use in a Markdown table cell.  Pipes would end the cell and newlines the row.
+var mdCell = strings.NewReplacer(`|`, `\|`, "\n", " ", "\r", " ")
+
+// mdCode returns s as an inline code span, so that Markdown doesn't interpret characters such as * or _ in paths.
+func mdCode(s string) string {
+	s = mdCell.Replace(s)
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+// writeMarkdownRow writes cells as a row of a Markdown table.
+func writeMarkdownRow(w *bufio.Writer, cells []string) {
+	w.WriteString("|")
+	for _, c := range cells {
+		w.WriteString(" " + c + " |")
+	}
+	w.WriteString("\n")
+}
+
+// writeMarkdownSection writes a Markdown table with a row per FileRec, like writeSection.  Sizes and percentages are
+// aligned right.
+func writeMarkdownSection(w *bufio.Writer, header string, recs []*FileRec, total int64, count int, othersKey string,
+	rootSize int64, opts *reportOptions) {
+
+	headers := strings.Split(opts.withMidHeaders(header), "\t")
+	for i := range headers {
+		headers[i] = mdCell.Replace(headers[i])
+	}
+	writeMarkdownRow(w, headers)
+	aligns := make([]string, len(headers))
+	for i := range aligns {
+		aligns[i] = "---"
+	}
+	aligns[0] = "---:"
+	if opts.Percent {
+		aligns[1] = "---:"
+	}
+	writeMarkdownRow(w, aligns)
+
+	for _, e := range recs {
+		cells := append([]string{opts.size(e.Size)}, opts.midCells(e, e.Size, rootSize)...)
+		cells = append(cells, mdCode(opts.path(e)))
+		if opts.Owners && e.FileInfo.IsDir() {
+			cells = append(cells, mdCell.Replace(formatOwners(e.Owners, opts)))
+		}
+		for _, c := range opts.trailingCells(e) {
+			cells = append(cells, mdCell.Replace(c))
+		}
+		writeMarkdownRow(w, cells)
+		total -= e.Size
+		count--
+	}
+	if count > 0 {
+		cells := append([]string{opts.size(total)}, opts.midCells(nil, total, rootSize)...)
+		cells = append(cells, "*"+mdCell.Replace(opts.msg(othersKey, count))+"*")
+		for len(cells) < len(headers) {
+			cells = append(cells, "")
+		}
+		writeMarkdownRow(w, cells)
+	}
+	w.WriteString("\n")
+}
+
+// writeMarkdown writes res to w as GitHub flavored Markdown tables, ready to paste into an issue or a wiki.
+func writeMarkdown(w io.Writer, res *Results, opts *reportOptions) error {
+	bw := bufio.NewWriter(w)
+	if opts.Files {
+		header := strings.Join(append([]string{opts.header("files.header")}, opts.trailingHeaders()...), "\t")
+		writeMarkdownSection(bw, header, res.Files, res.Stats.FileBytes, res.Stats.FileCount,
+			"others.files", res.Stats.DirBytes, opts)
+	}
+	if opts.Dirs {
+		header := opts.header("dirs.header")
+		if opts.Owners {
+			header += "\t" + opts.header("owners.header")
+		}
+		header = strings.Join(append([]string{header}, opts.trailingHeaders()...), "\t")
+		writeMarkdownSection(bw, header, res.Dirs, res.Stats.DirBytes, res.Stats.DirCount, "others.dirs",
+			res.Stats.DirBytes, opts)
+	}
+	if opts.Special {
+		writeMarkdownRow(bw, strings.Split(mdCell.Replace(opts.msg("special.header")), "\t"))
+		writeMarkdownRow(bw, []string{"---", "---"})
+		for _, e := range res.Special {
+			writeMarkdownRow(bw, []string{mdCell.Replace(opts.msg("special." + specialKind(e.FileInfo))),
+				mdCode(opts.path(e))})
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+)
+
+// formatNCDU is the name of the output format writing ncdu's JSON export format, which ncdu -f can browse.  Unlike
+// the other formats it holds every entry of the scan, so they're collected by an ncduExport as the scan runs.
+const formatNCDU = "ncdu"
+
+// An ncduEntry is the information ncdu's export format holds about a file or directory.  See
+// https://dev.yorhel.nl/ncdu/jsonfmt.
+type ncduEntry struct {
+	Name      string  `json:"name"`
+	Asize     int64   `json:"asize"`
+	Dsize     int64   `json:"dsize,omitempty"`
+	Dev       uint64  `json:"dev,omitempty"` // Only when it differs from the parent's.
+	Ino       uint64  `json:"ino,omitempty"`
+	Hlnkc     bool    `json:"hlnkc,omitempty"`
+	Nlink     uint64  `json:"nlink,omitempty"` // With Hlnkc.
+	ReadError bool    `json:"read_error,omitempty"`
+	Notreg    bool    `json:"notreg,omitempty"`
+	UID       *uint32 `json:"uid,omitempty"`
+	GID       *uint32 `json:"gid,omitempty"`
+	Mtime     int64   `json:"mtime"`
+}
+
+// An ncduExport collects every entry of a scan, so it can be written out as a tree once the scan is over.
+type ncduExport struct {
+	children map[*FileRec][]*FileRec // The entries of each directory.
+}
+
+func newNCDUExport() *ncduExport {
+	return &ncduExport{children: map[*FileRec][]*FileRec{}}
+}
+
+// Add records fr.  It's meant to be used as a Scanner's Each function.
+func (ne *ncduExport) Add(fr *FileRec) {
+	ne.children[fr.Dir] = append(ne.children[fr.Dir], fr)
+}
+
+// Write writes the collected tree below the root of res to w in ncdu's export format.
+func (ne *ncduExport) Write(w io.Writer, res *Results, opts *reportOptions) error {
+	denied := map[string]bool{}
+	for _, p := range res.Denied {
+		denied[p] = true
+	}
+	bw := bufio.NewWriter(w)
+	meta, _ := json.Marshal(map[string]interface{}{
+		"progname":  "bff",
+		"progver":   version,
+		"timestamp": opts.Run.Start.Unix(),
+	})
+	bw.WriteString("[1,2,")
+	bw.Write(meta)
+	bw.WriteString(",\n")
+	if err := ne.write(bw, res.Root, 0, denied, opts); err != nil {
+		return err
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
+
+// write writes fr, and everything below it if it's a directory, found on device parentDev.
+func (ne *ncduExport) write(bw *bufio.Writer, fr *FileRec, parentDev uint64, denied map[string]bool,
+	opts *reportOptions) error {
+
+	e := ncduEntry{Name: fr.Name, Asize: fr.FileInfo.Size(), Mtime: fr.FileInfo.ModTime().Unix()}
+	switch {
+	case fr.Dir == nil:
+		e.Name = opts.path(fr)
+	case opts.Redact != nil:
+		e.Name = path.Base(opts.path(fr))
+	}
+	if dev, bytes, ok := allocated(fr.FileInfo); ok {
+		e.Dsize = bytes
+		if dev != parentDev {
+			e.Dev = dev
+		}
+		parentDev = dev
+	}
+	if key, nlink, ok := fileID(fr.FileInfo); ok {
+		e.Ino = key.ino
+		if nlink > 1 && !fr.FileInfo.IsDir() {
+			e.Hlnkc, e.Nlink = true, nlink
+		}
+	}
+	if uid, gid, ok := fileOwner(fr.FileInfo); ok {
+		e.UID, e.GID = &uid, &gid
+	}
+	isDir := fr.FileInfo.IsDir()
+	e.Notreg = !isDir && !fr.FileInfo.Mode().IsRegular()
+	e.ReadError = isDir && denied[fr.Path()]
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if !isDir {
+		_, err = bw.Write(data)
+		return err
+	}
+
+	bw.WriteByte('[')
+	bw.Write(data)
+	children := ne.children[fr]
+	sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	for _, c := range children {
+		bw.WriteString(",\n")
+		if err := ne.write(bw, c, parentDev, denied, opts); err != nil {
+			return err
+		}
+	}
+	_, err = bw.WriteString("]")
+	return err
+}
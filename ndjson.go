@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// formatNDJSON is the name of the output format listing every entry as a JSON object per line.  Unlike the other
+// formats it's written entry by entry, so it has no reportWriter.  Entries are replayed in size order once the scan is
+// over, or with -stream, written as the walking go routines finish them, in an order which varies from run to run.
+const formatNDJSON = "ndjson"
+
+// An ndjsonRec is a jsonRec tagged with the run it belongs to.
+type ndjsonRec struct {
+	SchemaVersion int    `json:"schema_version"`
+	RunID         string `json:"run_id"`
+	jsonRec
+}
+
+// An ndjsonWriter writes scanned entries as newline delimited JSON, one object per entry, once each is final.
+// Directories are final once everything below them was scanned, so they follow their contents.
+type ndjsonWriter struct {
+	enc  *json.Encoder
+	root *FileRec
+	opts *reportOptions
+	err  error // The first write error, if any.
+}
+
+func newNDJSONWriter(w io.Writer, root *FileRec, opts *reportOptions) *ndjsonWriter {
+	return &ndjsonWriter{enc: json.NewEncoder(w), root: root, opts: opts}
+}
+
+// Write emits fr, if the report includes its kind of entry.  It's meant to be used as a Scanner's Each function,
+// and for the root once the scan is over.
+func (nw *ndjsonWriter) Write(fr *FileRec) {
+	switch {
+	case nw.err != nil:
+		return
+	case isSpecial(fr.FileInfo):
+		if !nw.opts.Special {
+			return
+		}
+	case fr.FileInfo.IsDir():
+		if !nw.opts.Dirs {
+			return
+		}
+	case !nw.opts.Files:
+		return
+	}
+	nw.err = nw.enc.Encode(ndjsonRec{SchemaVersion: jsonSchemaVersion, RunID: nw.opts.Run.ID, jsonRec: newJSONRec(fr, nw.root, nw.opts)})
+}
+
+// Err returns the first error encountered while writing.
+func (nw *ndjsonWriter) Err() error {
+	return nw.err
+}
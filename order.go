@@ -0,0 +1,34 @@
+package main
+
+import "sort"
+
+// An entryOrder holds back scanned entries, which arrive in whatever order the walking go routines happen to finish
+// them, so they can be replayed in the same order on every run.  Outputs listing every entry without ranking them,
+// like -du a, folded stacks and ndjson, use it to stay deterministic.
+type entryOrder struct {
+	children map[*FileRec][]*FileRec // The entries of each directory.
+}
+
+func newEntryOrder() *entryOrder {
+	return &entryOrder{children: map[*FileRec][]*FileRec{}}
+}
+
+// Add records fr.  It's meant to be used as a Scanner's Each function.
+func (eo *entryOrder) Add(fr *FileRec) {
+	eo.children[fr.Dir] = append(eo.children[fr.Dir], fr)
+}
+
+// Each calls f for every entry recorded below dir, in the order the ranked reports use: each directory's entries
+// largest first, then by path.  As during the scan, everything below a directory comes before the directory itself.
+// The entries are forgotten as they're replayed.
+func (eo *entryOrder) Each(dir *FileRec, f func(fr *FileRec)) {
+	children := eo.children[dir]
+	delete(eo.children, dir)
+	sort.Sort(bySize(children))
+	for _, c := range children {
+		if c.FileInfo.IsDir() {
+			eo.Each(c, f)
+		}
+		f(c)
+	}
+}
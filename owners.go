@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// topOwnersCount is the number of owners kept for each directory.
+const topOwnersCount = 3
+
+// An OwnerShare is the number of bytes within a directory owned by one user.
+type OwnerShare struct {
+	UID   uint32
+	Bytes int64
+}
+
+// topOwners returns the topOwnersCount largest shares of bytesByUID, largest first.
+func topOwners(bytesByUID map[uint32]int64) []OwnerShare {
+	shares := make([]OwnerShare, 0, len(bytesByUID))
+	for uid, bytes := range bytesByUID {
+		shares = append(shares, OwnerShare{UID: uid, Bytes: bytes})
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Bytes != shares[j].Bytes {
+			return shares[i].Bytes > shares[j].Bytes
+		}
+		return shares[i].UID < shares[j].UID
+	})
+	if len(shares) > topOwnersCount {
+		shares = shares[:topOwnersCount]
+	}
+	return shares
+}
+
+// userNames caches user name lookups, which can be slow when they go over the network.
+var userNames = struct {
+	sync.Mutex
+	byUID map[uint32]string
+}{byUID: map[uint32]string{}}
+
+// userName returns the name of the user with the given uid, or the uid itself if it can't be resolved.
+func userName(uid uint32) string {
+	userNames.Lock()
+	defer userNames.Unlock()
+	if name, ok := userNames.byUID[uid]; ok {
+		return name
+	}
+
+	id := strconv.FormatUint(uint64(uid), 10)
+	name := id
+	if u, err := user.LookupId(id); err == nil {
+		name = u.Username
+	}
+	userNames.byUID[uid] = name
+	return name
+}
+
+// groupNames caches group name lookups, like userNames.
+var groupNames = struct {
+	sync.Mutex
+	byGID map[uint32]string
+}{byGID: map[uint32]string{}}
+
+// groupName returns the name of the group with the given gid or, if numeric is set or it can't be resolved, the gid
+// itself.
+func groupName(gid uint32, numeric bool) string {
+	id := strconv.FormatUint(uint64(gid), 10)
+	if numeric {
+		return id
+	}
+	groupNames.Lock()
+	defer groupNames.Unlock()
+	if name, ok := groupNames.byGID[gid]; ok {
+		return name
+	}
+
+	name := id
+	if g, err := user.LookupGroupId(id); err == nil {
+		name = g.Name
+	}
+	groupNames.byGID[gid] = name
+	return name
+}
+
+// ownerName returns the name of the user with the given uid or, if numeric is set, the uid itself.
+func ownerName(uid uint32, numeric bool) string {
+	if numeric {
+		return strconv.FormatUint(uint64(uid), 10)
+	}
+	return userName(uid)
+}
+
+// formatOwners renders shares as e.g. "alice 1048576, bob 4096", with uids and sizes shown as opts asks for.
+func formatOwners(shares []OwnerShare, opts *reportOptions) string {
+	strs := make([]string, 0, len(shares))
+	for _, sh := range shares {
+		strs = append(strs, fmt.Sprintf("%v %v", ownerName(sh.UID, opts.Numeric), opts.size(sh.Bytes)))
+	}
+	return strings.Join(strs, ", ")
+}
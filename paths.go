@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// A prefixMapping rewrites paths below From to appear below To instead.
+type prefixMapping struct {
+	From string
+	To   string
+}
+
+// prefixMap is a flag.Value collecting repeated -map-prefix from=to options.
+type prefixMap []prefixMapping
+
+func (pm *prefixMap) String() string {
+	strs := []string{}
+	for _, m := range *pm {
+		strs = append(strs, m.From+"="+m.To)
+	}
+	return strings.Join(strs, ",")
+}
+
+func (pm *prefixMap) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 1 {
+		return fmt.Errorf("invalid mapping %q, expected from=to", s)
+	}
+	from, to := filepath.Clean(s[:i]), s[i+1:]
+	if to == "" {
+		to = "/"
+	}
+	*pm = append(*pm, prefixMapping{From: from, To: filepath.Clean(to)})
+	return nil
+}
+
+// Map rewrites p using the longest matching mapping.  Prefixes only match whole path components, so /host/root
+// doesn't match /host/rootfs.
+func (pm prefixMap) Map(p string) string {
+	best := -1
+	for i, m := range pm {
+		if (p == m.From || strings.HasPrefix(p, m.From+"/") || m.From == "/") &&
+			(best < 0 || len(m.From) > len(pm[best].From)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return p
+	}
+	return filepath.Join(pm[best].To, strings.TrimPrefix(p, pm[best].From))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// A pluginVerdict is what a plugin says about one of the entries it was sent.  Plugins are external executables,
+// given the reported entries as ndjson on standard input, one ndjsonRec per line.  They may answer with verdicts on
+// standard output, one JSON object per line, about any of them.  A plugin which only acts on what it's sent, e.g. a
+// notifier, needn't write anything.
+type pluginVerdict struct {
+	Path       string `json:"path"`       // The path of the entry, exactly as the plugin was sent it.
+	Annotation string `json:"annotation"` // If set, shown as the entry's annotation.
+	Hide       bool   `json:"hide"`       // Leave the entry out of the report.
+}
+
+// pluginList is a flag.Value collecting repeated -plugin commands.
+type pluginList []string
+
+func (pl *pluginList) String() string {
+	return strings.Join(*pl, ",")
+}
+
+func (pl *pluginList) Set(s string) error {
+	if len(strings.Fields(s)) == 0 {
+		return errors.New("empty plugin command")
+	}
+	*pl = append(*pl, s)
+	return nil
+}
+
+// runPlugin runs cmdline, an executable followed by its arguments separated by spaces, as a plugin over the reported
+// entries of res.  Annotations it gives are added to opts.Labels, and entries it hides are removed from res.  The
+// plugin's standard error goes to ours.
+func runPlugin(cmdline string, res *Results, opts *reportOptions) error {
+	args := strings.Fields(cmdline)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// Entries are sent from a go routine, so a plugin answering as it reads can't block on a full pipe.
+	sent := map[string]*FileRec{}
+	recs := [][]*FileRec{}
+	for _, section := range []struct {
+		include bool
+		recs    []*FileRec
+	}{{opts.Files, res.Files}, {opts.Dirs, res.Dirs}, {opts.Special, res.Special}} {
+		if section.include {
+			recs = append(recs, section.recs)
+			for _, fr := range section.recs {
+				sent[opts.path(fr)] = fr
+			}
+		}
+	}
+	go func() {
+		bw := bufio.NewWriter(stdin)
+		enc := json.NewEncoder(bw)
+		for _, section := range recs {
+			for _, fr := range section {
+				if enc.Encode(ndjsonRec{SchemaVersion: jsonSchemaVersion, RunID: opts.Run.ID,
+					jsonRec: newJSONRec(fr, res.Root, opts)}) != nil {
+					break
+				}
+			}
+		}
+		bw.Flush()
+		stdin.Close()
+	}()
+
+	hidden := map[*FileRec]bool{}
+	sc := bufio.NewScanner(stdout)
+	for sc.Scan() {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		v := pluginVerdict{}
+		if err := json.Unmarshal(sc.Bytes(), &v); err != nil {
+			io.Copy(io.Discard, stdout)
+			cmd.Wait()
+			return fmt.Errorf("invalid verdict %q: %v", sc.Text(), err)
+		}
+		fr, ok := sent[v.Path]
+		if !ok {
+			continue
+		}
+		if v.Annotation != "" {
+			if opts.Labels == nil {
+				opts.Labels = map[*FileRec]string{}
+			}
+			opts.Labels[fr] = v.Annotation
+		}
+		hidden[fr] = hidden[fr] || v.Hide
+	}
+	io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return err
+	}
+
+	unhidden := func(recs []*FileRec) []*FileRec {
+		kept := recs[:0]
+		for _, fr := range recs {
+			if !hidden[fr] {
+				kept = append(kept, fr)
+			}
+		}
+		return kept
+	}
+	res.Files, res.Dirs, res.Special = unhidden(res.Files), unhidden(res.Dirs), unhidden(res.Special)
+	return nil
+}
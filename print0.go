@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bufio"
+	"io"
+)
+
+// writePrint0 writes just the paths of the reported entries, each followed by a NUL byte, for xargs -0 and the like.
+// Unlike newlines, NUL can't appear in a path, so any path survives the trip.
+func writePrint0(w io.Writer, res *Results, opts *reportOptions) error {
+	bw := bufio.NewWriter(w)
+	for _, section := range []struct {
+		include bool
+		recs    []*FileRec
+	}{{opts.Files, res.Files}, {opts.Dirs, res.Dirs}, {opts.Special, res.Special}} {
+		if !section.include {
+			continue
+		}
+		for _, fr := range section.recs {
+			bw.WriteString(opts.path(fr))
+			bw.WriteByte(0)
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// probeUnreadable returns the directories among the entries of root which can't be listed.  It only tries to read
+// one name from each, so it's quick enough to run before the scan starts.
+func probeUnreadable(root *FileRec) []string {
+	unreadable := []string{}
+	for _, e := range root.Contents {
+		if !e.IsDir() {
+			continue
+		}
+		p := filepath.Join(root.Path(), e.Name())
+		dir, err := os.Open(p)
+		if err == nil {
+			_, err = dir.Readdirnames(1)
+			dir.Close()
+		}
+		if os.IsPermission(err) {
+			unreadable = append(unreadable, p)
+		}
+	}
+	return unreadable
+}
+
+// reexecDropped holds the options left out of sudo rescans, and whether each takes a value.  Apart from -sudo-reexec
+// itself, they name files or addresses which the rescans would otherwise overwrite, or fight over, with this run.
+var reexecDropped = map[string]bool{
+	"sudo-reexec": false,
+	"o":           true,
+	"flamegraph":  true,
+	"graph":       true,
+	"trace":       true,
+	"pprof":       true,
+}
+
+// sudoReexec runs bff again through sudo over each of paths, with the same options as this run except those in
+// reexecDropped.  The reports go to our own stdout and stderr, even if this run's report went to a file.
+func sudoReexec(paths []string, args []string) {
+	self, err := os.Executable()
+	if err != nil {
+		log.Printf("failed to find the bff executable: %v", err)
+		return
+	}
+
+	opts := []string{}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		name := strings.TrimLeft(strings.SplitN(a, "=", 2)[0], "-")
+		hasValue, dropped := reexecDropped[name]
+		switch {
+		case !dropped:
+			opts = append(opts, a)
+		case hasValue && !strings.Contains(a, "="):
+			i++ // The value is the next argument.
+		}
+	}
+
+	for _, p := range paths {
+		log.Printf("rescanning %v with sudo", p)
+		cmd := exec.Command("sudo", append(append([]string{"--", self}, opts...), p)...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Printf("failed to rescan %v: %v", p, err)
+		}
+	}
+}
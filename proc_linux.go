@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// eachProcess calls fn with the ID, /proc directory and name of every process.
+func eachProcess(fn func(pid int, dir, comm string)) error {
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return err
+	}
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil {
+			continue
+		}
+		dir := "/proc/" + p.Name()
+		comm, _ := os.ReadFile(dir + "/comm")
+		fn(pid, dir, strings.TrimSpace(string(comm)))
+	}
+	return nil
+}
+
+// eachOpenFile calls fn for every file descriptor of every process it can read, with the process ID and name, the
+// target of the descriptor as /proc shows it, and the FileInfo of the file it refers to.  Processes which can't be
+// read, usually for lack of permission, are counted and skipped.
+func eachOpenFile(fn func(pid int, comm, target string, fi os.FileInfo)) (unreadable int, err error) {
+	err = eachProcess(func(pid int, dir, comm string) {
+		fds, err := os.ReadDir(dir + "/fd")
+		if err != nil {
+			unreadable++
+			return
+		}
+		for _, fd := range fds {
+			fdPath := dir + "/fd/" + fd.Name()
+			target, err := os.Readlink(fdPath)
+			if err != nil {
+				continue
+			}
+			// Stat follows the descriptor's magic link to the open file itself, even once it's deleted.
+			fi, err := os.Stat(fdPath)
+			if err != nil {
+				continue
+			}
+			fn(pid, comm, target, fi)
+		}
+	})
+	return unreadable, err
+}
+
+// eachMappedFile calls fn for every file mapped into the memory of every process it can read, with the process ID
+// and name, the identity of the file and its path.  Processes which can't be read are skipped.
+func eachMappedFile(fn func(pid int, comm string, key fileKey, path string)) error {
+	return eachProcess(func(pid int, dir, comm string) {
+		f, err := os.Open(dir + "/maps")
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		// Lines look like "7f2a3c000000-7f2a3c021000 r--p 00000000 fd:01 1234567 /usr/lib/libc.so.6".
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			fields := strings.Fields(sc.Text())
+			if len(fields) < 6 || !strings.HasPrefix(fields[5], "/") {
+				continue
+			}
+			major, minor, ok := strings.Cut(fields[3], ":")
+			if !ok {
+				continue
+			}
+			maj, err1 := strconv.ParseUint(major, 16, 32)
+			min, err2 := strconv.ParseUint(minor, 16, 32)
+			ino, err3 := strconv.ParseUint(fields[4], 10, 64)
+			if err1 != nil || err2 != nil || err3 != nil || ino == 0 {
+				continue
+			}
+			fn(pid, comm, fileKey{dev: mkdev(maj, min), ino: ino}, strings.Join(fields[5:], " "))
+		}
+	})
+}
+
+// mkdev encodes a device number from its major and minor numbers, the way Linux does in st_dev.
+func mkdev(major, minor uint64) uint64 {
+	return (major&0x00000fff)<<8 | (major&0xfffff000)<<32 | (minor & 0x000000ff) | (minor&0xffffff00)<<12
+}
+
+// processHolders finds which processes hold which files open, through their file descriptors or memory mappings.  It
+// also returns the number of processes whose open files couldn't be read.
+func processHolders() (*procHolders, int, error) {
+	ph := newProcHolders()
+	unreadable, err := eachOpenFile(func(pid int, comm, target string, fi os.FileInfo) {
+		if !strings.HasPrefix(target, "/") || strings.HasSuffix(target, " (deleted)") {
+			return
+		}
+		if key, _, ok := fileID(fi); ok {
+			ph.add(key, target, pid, comm)
+		}
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	err = eachMappedFile(func(pid int, comm string, key fileKey, path string) {
+		if !strings.HasSuffix(path, " (deleted)") {
+			ph.add(key, path, pid, comm)
+		}
+	})
+	return ph, unreadable, err
+}
+
+// openDeletedFiles returns the regular files on device dev of at least min bytes which have been deleted but are
+// still held open, largest first.  It also returns the number of processes whose open files couldn't be read.
+func openDeletedFiles(dev uint64, min int64) ([]*deletedFile, int, error) {
+	files := map[fileKey]*deletedFile{}
+	unreadable, err := eachOpenFile(func(pid int, comm, target string, fi os.FileInfo) {
+		path, ok := strings.CutSuffix(target, " (deleted)")
+		if !ok || !fi.Mode().IsRegular() || fi.Size() < min {
+			return
+		}
+		key, _, ok := fileID(fi)
+		if !ok || key.dev != dev {
+			return
+		}
+		f := files[key]
+		if f == nil {
+			f = &deletedFile{Path: path, Size: fi.Size()}
+			files[key] = f
+		}
+		f.addProc(pid, comm)
+	})
+	return sortDeletedFiles(files), unreadable, err
+}
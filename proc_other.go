@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// openDeletedFiles is only implemented on Linux, where /proc lists the files each process holds open.
+func openDeletedFiles(dev uint64, min int64) ([]*deletedFile, int, error) {
+	return nil, 0, fmt.Errorf("finding deleted files is not supported on %v", runtime.GOOS)
+}
+
+// processHolders is only implemented on Linux, where /proc lists the files each process holds open.
+func processHolders() (*procHolders, int, error) {
+	return nil, 0, fmt.Errorf("attributing files to processes is not supported on %v", runtime.GOOS)
+}
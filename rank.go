@@ -0,0 +1,61 @@
+package main
+
+import (
+	"container/heap"
+	"log"
+	"sort"
+)
+
+// A ranking keeps the largest FileRecs offered to it, up to a limit.  It's backed by a min-heap with the smallest
+// kept entry on top, so offering an entry costs O(log limit) however large the limit is.  A limit of 0 keeps
+// everything, unless memory runs short, in which case the ranking freezes at its current size.
+type ranking struct {
+	limit int
+	recs  []*FileRec
+}
+
+func newRanking(limit int) *ranking {
+	return &ranking{limit: limit, recs: []*FileRec{}}
+}
+
+// heap.Interface, ordered so that the smallest FileRec is at the root.
+func (r *ranking) Len() int           { return len(r.recs) }
+func (r *ranking) Swap(i, j int)      { r.recs[i], r.recs[j] = r.recs[j], r.recs[i] }
+func (r *ranking) Less(i, j int) bool { return bySize(r.recs).Less(j, i) }
+func (r *ranking) Push(x interface{}) { r.recs = append(r.recs, x.(*FileRec)) }
+func (r *ranking) Pop() interface{} {
+	fr := r.recs[len(r.recs)-1]
+	r.recs = r.recs[:len(r.recs)-1]
+	return fr
+}
+
+// Offer considers fr for the ranking, keeping it if it's among the largest seen so far.
+func (r *ranking) Offer(fr *FileRec) {
+	if r.limit == 0 {
+		if !memory.Shedding() {
+			r.recs = append(r.recs, fr)
+			return
+		}
+		// Keep at least one entry, as a limit of 0 would lift the limit again.
+		r.limit = len(r.recs)
+		if r.limit < 1 {
+			r.limit = 1
+		}
+		log.Printf("keeping only the largest %v entries of an unlimited listing", r.limit)
+		heap.Init(r)
+	}
+
+	if len(r.recs) < r.limit {
+		heap.Push(r, fr)
+	} else if r.limit > 0 && bySize([]*FileRec{fr, r.recs[0]}).Less(0, 1) {
+		r.recs[0] = fr
+		heap.Fix(r, 0)
+	}
+}
+
+// Sorted returns the kept FileRecs, sorted from largest to smallest.  The ranking shouldn't be offered any more
+// entries afterwards.
+func (r *ranking) Sorted() []*FileRec {
+	sort.Sort(bySize(r.recs))
+	return r.recs
+}
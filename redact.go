@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Redaction modes, as selected by -redact.
+const (
+	redactHash     = "hash"     // Every path component is replaced by a hash.
+	redactBasename = "basename" // Directory components are replaced by hashes, base names are kept.
+)
+
+// A redactor obfuscates path components while keeping the structure of paths: the same name always maps to the
+// same hash within a run.  Hashes are keyed with a random per-run key, so common names can't be looked up.
+type redactor struct {
+	mode string
+	key  []byte
+}
+
+// newRedactor returns a redactor for mode, or nil if mode is empty.
+func newRedactor(mode string) (*redactor, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case redactHash, redactBasename:
+	default:
+		return nil, fmt.Errorf("unknown redaction mode %q, must be %v or %v", mode, redactHash, redactBasename)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &redactor{mode: mode, key: key}, nil
+}
+
+// hash returns the obfuscated form of a single path component.
+func (r *redactor) hash(name string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(name))
+	return hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// Redact obfuscates the components of the slash separated path p.  A nil redactor returns p unchanged.
+func (r *redactor) Redact(p string) string {
+	if r == nil {
+		return p
+	}
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if part == "" || (r.mode == redactBasename && i == len(parts)-1) {
+			continue
+		}
+		parts[i] = r.hash(part)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// A bucket is a size threshold used to group report entries into bands.
+type bucket struct {
+	size  int64  // Lower bound of the band, in bytes.
+	label string // The threshold as the user wrote it.
+}
+
+// parseBuckets parses a comma separated list of sizes, such as "1G,10G,100G", into buckets sorted smallest first.
+func parseBuckets(s string) ([]bucket, error) {
+	buckets := []bucket{}
+	for _, str := range strings.Split(s, ",") {
+		str = strings.TrimSpace(str)
+		size, err := parseSize(str)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket{size: size, label: str})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].size < buckets[j].size })
+	return buckets, nil
+}
+
+// bandLabel returns the label of the band size falls into, e.g. "10G–100G", in lang.  If plain is set, the range is
+// written with an ASCII hyphen.
+func bandLabel(size int64, buckets []bucket, lang string, plain bool) string {
+	// Find the first threshold above size.
+	i := sort.Search(len(buckets), func(i int) bool { return buckets[i].size > size })
+	switch i {
+	case 0:
+		return msg(lang, "band.under", buckets[0].label)
+	case len(buckets):
+		return msg(lang, "band.over", buckets[i-1].label)
+	default:
+		if plain {
+			return buckets[i-1].label + "-" + buckets[i].label
+		}
+		return buckets[i-1].label + "–" + buckets[i].label
+	}
+}
+
+// reportOptions controls what goes into a report and how it's laid out.
+type reportOptions struct {
+	Files      bool                // Include the files section.
+	Dirs       bool                // Include the directories section.
+	Special    bool                // Include the special files section.
+	Owners     bool                // Show the top owners of each directory.
+	Numeric    bool                // Show user and group IDs rather than resolving them to names.
+	Buckets    []bucket            // If set, group entries into labeled size bands.
+	Prefixes   prefixMap           // Rewrite path prefixes, e.g. from a host's view of a container to the container's.
+	Redact     *redactor           // If set, obfuscate path components.
+	Relative   string              // If set, show paths relative to this directory, rather than mapped by Prefixes.
+	Lang       string              // Language of the report, one of the keys of translations.
+	Plain      bool                // Tab separated columns in a fixed order, no padding and no decoration, for screen readers.
+	Percent    bool                // Show each size as a share of the scanned directory's total size.
+	Types      bool                // Show the type of each entry, as written by fileType.
+	Long       bool                // Show the mtime, owner and group of each entry.
+	Group      bool                // Group the digits of byte counts in thousands, with the report language's separator.
+	Human      bool                // Show sizes with units, e.g. "1.5 GiB", rather than as byte counts.
+	SI         bool                // With Human, use powers of 1000 (kB, MB, GB) rather than of 1024.
+	Color      bool                // Color sizes by RedSize and YellowSize, and directories differently from files.
+	RedSize    int64               // With Color, sizes from this one up are red.
+	YellowSize int64               // With Color, sizes from this one up, and below RedSize, are yellow.  Smaller ones are green.
+	Run        *runInfo            // Describes the run, for machine readable formats.
+	Tree       *sizeTree           // The built directory tree, for formats which draw it, or nil if it wasn't kept.
+	Holders    *procHolders        // If set, show the processes holding each entry open.
+	GraphNodes int                 // Number of directories in graph formats.
+	System     []*systemFile       // System files found below the scan root, such as swap files, largest first.
+	Notes      annotations         // If set, label each entry with the annotation of its path.
+	Labels     map[*FileRec]string // Annotations given to entries by plugins, which take precedence over Notes.
+}
+
+// msg returns the message for key in the report's language, formatted with args.
+func (ro *reportOptions) msg(key string, args ...interface{}) string {
+	return msg(ro.Lang, key, args...)
+}
+
+// header returns the column header for key in the report's language, formatted with args.  Headers mention the unit
+// of sizes, so there's a variant without it for when sizes are human readable.
+func (ro *reportOptions) header(key string, args ...interface{}) string {
+	if ro.Human {
+		key += ".human"
+	}
+	return ro.msg(key, args...)
+}
+
+// size formats a byte count as it should appear in the report.
+func (ro *reportOptions) size(n int64) string {
+	switch {
+	case !ro.Human && ro.Group:
+		return groupDigits(n, ro.msg("digits.group"))
+	case !ro.Human:
+		return strconv.FormatInt(n, 10)
+	case ro.SI:
+		return siSize(n)
+	default:
+		return humanSize(n)
+	}
+}
+
+// percent formats size as a share of rootSize.
+func (ro *reportOptions) percent(size, rootSize int64) string {
+	share := 0.0
+	if rootSize > 0 {
+		share = 100 * float64(size) / float64(rootSize)
+	}
+	return fmt.Sprintf("%.1f%%", share)
+}
+
+// groupDigits formats n with its digits grouped in thousands by sep, e.g. "1,234,567".
+func groupDigits(n int64, sep string) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	sb := strings.Builder{}
+	sb.WriteString(sign)
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteString(sep)
+		}
+		sb.WriteRune(d)
+	}
+	return sb.String()
+}
+
+// longTimeFormat is the layout of mtimes in long reports.
+const longTimeFormat = "2006-01-02 15:04"
+
+// midHeaders returns the headers of the optional columns shown between sizes and paths.
+func (ro *reportOptions) midHeaders() []string {
+	headers := []string{}
+	if ro.Percent {
+		headers = append(headers, "%")
+	}
+	if ro.Types {
+		headers = append(headers, ro.msg("type.header"))
+	}
+	if ro.Long {
+		headers = append(headers, ro.msg("mtime.header"), ro.msg("owner.header"), ro.msg("group.header"))
+	}
+	return headers
+}
+
+// withMidHeaders inserts the headers of the optional columns into header, after the size.
+func (ro *reportOptions) withMidHeaders(header string) string {
+	if mid := ro.midHeaders(); len(mid) > 0 {
+		return strings.Replace(header, "\t", "\t"+strings.Join(mid, "\t")+"\t", 1)
+	}
+	return header
+}
+
+// midCells returns the optional cells shown between the size and the path of fr, or of the row accounting for the
+// rest of a section if fr is nil.  Percentages are of rootSize.
+func (ro *reportOptions) midCells(fr *FileRec, size, rootSize int64) []string {
+	cells := []string{}
+	if ro.Percent {
+		cells = append(cells, ro.percent(size, rootSize))
+	}
+	if ro.Types {
+		kind := ""
+		if fr != nil {
+			kind = fileType(fr.FileInfo)
+		}
+		cells = append(cells, kind)
+	}
+	if ro.Long {
+		mtime, owner, group := "", "", ""
+		if fr != nil {
+			mtime = fr.FileInfo.ModTime().Format(longTimeFormat)
+			if uid, gid, ok := fileOwner(fr.FileInfo); ok {
+				owner, group = ownerName(uid, ro.Numeric), groupName(gid, ro.Numeric)
+			}
+		}
+		cells = append(cells, mtime, owner, group)
+	}
+	return cells
+}
+
+// trailingHeaders returns the headers of the optional columns shown after paths in every tabular format, other than
+// the owners of directories.
+func (ro *reportOptions) trailingHeaders() []string {
+	headers := []string{}
+	if ro.Holders != nil {
+		headers = append(headers, ro.msg("holders.header"))
+	}
+	if ro.annotated() {
+		headers = append(headers, ro.msg("annotation.header"))
+	}
+	return headers
+}
+
+// trailingCells returns the cells of fr for the columns named by trailingHeaders.
+func (ro *reportOptions) trailingCells(fr *FileRec) []string {
+	cells := []string{}
+	if ro.Holders != nil {
+		cells = append(cells, strings.Join(ro.Holders.Of(fr), ", "))
+	}
+	if ro.annotated() {
+		cells = append(cells, ro.annotation(fr))
+	}
+	return cells
+}
+
+// sizeCells returns the cells showing size, painted in sgr, followed by the optional cells for fr.
+func (ro *reportOptions) sizeCells(fr *FileRec, size, rootSize int64, sgr string) string {
+	cells := ro.paint(sgr, ro.size(size))
+	for _, c := range ro.midCells(fr, size, rootSize) {
+		cells += "\t" + ro.paint(sgrDefault, c)
+	}
+	return cells
+}
+
+// mapPath returns p as it should appear in the report.
+func (ro *reportOptions) mapPath(p string) string {
+	if ro.Relative != "" {
+		if rel, err := filepath.Rel(ro.Relative, p); err == nil {
+			return ro.Redact.Redact(filepath.ToSlash(rel))
+		}
+	}
+	return ro.Redact.Redact(ro.Prefixes.Map(p))
+}
+
+// path returns the path of fr as it should appear in the report.
+func (ro *reportOptions) path(fr *FileRec) string {
+	return ro.mapPath(fr.Path())
+}
+
+// A reportWriter writes a report of res to w in one of the output formats.
+type reportWriter func(w io.Writer, res *Results, opts *reportOptions) error
+
+// reportWriters maps the names accepted by -format to their writers.
+var reportWriters = map[string]reportWriter{
+	"text": writeText,
+	"json": writeJSON,
+	"csv":  writeCSV,
+	"yaml": writeYAML,
+	"md":   writeMarkdown,
+	"html": writeHTML,
+	"dot":  writeDOTReport,
+}
+
+// writeSection writes a header followed by one line per FileRec.  If recs don't account for all count entries
+// totalling total bytes, a final row accounts for the rest, so that the section always sums to the scanned total.
+// othersKey is the message key for that row.  Percentages are of rootSize, the size of the whole scan.
+func writeSection(w io.Writer, header string, recs []*FileRec, total int64, count int, othersKey string,
+	rootSize int64, opts *reportOptions) {
+
+	fmt.Fprintln(w, opts.paintCells(opts.withMidHeaders(header)))
+	band := ""
+	for _, e := range recs {
+		if len(opts.Buckets) > 0 {
+			if l := bandLabel(e.Size, opts.Buckets, opts.Lang, opts.Plain); l != band {
+				band = l
+				if opts.Plain {
+					fmt.Fprintf(w, "%v:\n", band)
+				} else {
+					// Keep empty cells for the optional columns, so they stay aligned across bands.
+					fmt.Fprintf(w, "%v\t%v\n", opts.paint(sgrDefault, band+":"),
+						strings.Repeat("\t", len(opts.midHeaders())))
+				}
+			}
+		}
+		cells := []string{opts.sizeCells(e, e.Size, rootSize, opts.sizeColor(e.Size)),
+			opts.paint(opts.pathColor(e), opts.path(e))}
+		if opts.Owners && e.FileInfo.IsDir() {
+			cells = append(cells, formatOwners(e.Owners, opts))
+		}
+		cells = append(cells, opts.trailingCells(e)...)
+		// Trailing columns are left out rather than left empty.
+		for len(cells) > 2 && cells[len(cells)-1] == "" {
+			cells = cells[:len(cells)-1]
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+		total -= e.Size
+		count--
+	}
+	if count > 0 {
+		fmt.Fprintf(w, "%v\t%v\n", opts.sizeCells(nil, total, rootSize, sgrDefault),
+			opts.paint(sgrDefault, opts.msg(othersKey, count)))
+	}
+}
+
+// writeText writes the human readable report of res to w.  Columns are aligned, unless the report is plain.
+func writeText(w io.Writer, res *Results, opts *reportOptions) error {
+	out := w
+	tabW := &tabwriter.Writer{}
+	if !opts.Plain {
+		tabW.Init(w, 0, 8, 2, ' ', 0)
+		out = tabW
+	}
+	if opts.Files {
+		header := strings.Join(append([]string{opts.header("files.header")}, opts.trailingHeaders()...), "\t")
+		writeSection(out, header, res.Files, res.Stats.FileBytes, res.Stats.FileCount,
+			"others.files", res.Stats.DirBytes, opts)
+	}
+	if opts.Dirs {
+		header := opts.header("dirs.header")
+		if opts.Owners {
+			header += "\t" + opts.header("owners.header")
+		}
+		header = strings.Join(append([]string{header}, opts.trailingHeaders()...), "\t")
+		writeSection(out, header, res.Dirs, res.Stats.DirBytes, res.Stats.DirCount, "others.dirs",
+			res.Stats.DirBytes, opts)
+	}
+	if opts.Special {
+		fmt.Fprintln(out, opts.paintCells(opts.msg("special.header")))
+		for _, e := range res.Special {
+			fmt.Fprintln(out, opts.paintCells(opts.msg("special."+specialKind(e.FileInfo))+"\t"+opts.path(e)))
+		}
+	}
+	if len(opts.System) > 0 {
+		fmt.Fprintln(out, opts.paintCells(opts.withMidHeaders(opts.header("system.header"))))
+		for _, sf := range opts.System {
+			fmt.Fprintf(out, "%v\t%v\t%v\n", opts.sizeCells(nil, sf.Size, res.Stats.DirBytes, opts.sizeColor(sf.Size)),
+				opts.paint(sgrDefault, opts.mapPath(sf.Path)), opts.paint(sgrDefault, sf.note(opts)))
+		}
+	}
+	if opts.Run != nil {
+		fmt.Fprintln(out, opts.msg("summary", res.Stats.FileCount, res.Stats.DirCount, opts.size(res.Stats.DirBytes),
+			opts.Run.End.Sub(opts.Run.Start).Round(time.Millisecond), res.Stats.Errors))
+	}
+	if opts.Plain {
+		return nil
+	}
+	return tabW.Flush()
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// reveal shows path in the platform file manager.  Finder and Explorer open the containing directory with path
+// selected; elsewhere the containing directory is opened with xdg-open.
+func reveal(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", "-R", path)
+	case "windows":
+		cmd = exec.Command("explorer.exe", "/select,"+path)
+	default:
+		cmd = exec.Command("xdg-open", filepath.Dir(path))
+	}
+
+	// Don't hang around for the file manager, it may well outlive us.
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
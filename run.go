@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// version is the version of bff, set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+// A runInfo describes one run of bff, so that machine readable output can be correlated and reproduced.
+type runInfo struct {
+	ID       string            `json:"run_id"`
+	Hostname string            `json:"hostname"`
+	Root     string            `json:"root"`
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	Version  string            `json:"bff_version"`
+	Options  map[string]string `json:"options"` // Every option, with the value in effect.
+}
+
+// newRunID returns a random (version 4) UUID.
+func newRunID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// pathOptions are the options whose values may hold paths, and so are redacted along with the report's.
+var pathOptions = map[string]bool{
+	"include": true, "exclude": true, "map-prefix": true, "annotations": true, "flamegraph": true, "graph": true,
+	"o": true, "plugin": true, "trace": true,
+}
+
+// newRunInfo starts describing a run over root, with the options in fs, redacting the paths among them with r.  End
+// must be filled in once the scan is over.
+func newRunInfo(root string, fs *flag.FlagSet, r *redactor) (*runInfo, error) {
+	id, err := newRunID()
+	if err != nil {
+		return nil, err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	ri := &runInfo{
+		ID:       id,
+		Hostname: hostname,
+		Root:     root,
+		Start:    time.Now(),
+		Version:  version,
+		Options:  map[string]string{},
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		ri.Options[f.Name] = f.Value.String()
+		if pathOptions[f.Name] {
+			ri.Options[f.Name] = r.Redact(ri.Options[f.Name])
+		}
+	})
+	return ri, nil
+}
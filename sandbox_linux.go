@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Landlock system calls and constants, from linux/landlock.h.  The system call numbers are the same on every
+// architecture.
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1 << 0
+	landlockRulePathBeneath      = 1
+
+	landlockAccessFSReadFile = 1 << 2
+	landlockAccessFSReadDir  = 1 << 3
+	landlockAccessFSABI1     = 1<<13 - 1 // Every access right known to Landlock ABI 1.
+	landlockAccessFSRefer    = 1 << 13   // ABI 2.
+	landlockAccessFSTruncate = 1 << 14   // ABI 3.
+
+	prSetNoNewPrivs = 38
+)
+
+// landlockRulesetAttr is struct landlock_ruleset_attr, as of ABI 1.
+type landlockRulesetAttr struct {
+	handledAccessFS uint64
+}
+
+// landlockPathBeneathAttr is the packed struct landlock_path_beneath_attr.
+type landlockPathBeneathAttr struct {
+	allowedAccess uint64
+	parentFd      int32
+}
+
+// sandboxReadOnly uses Landlock to restrict the whole process to reading below the readable paths.  Nothing can be
+// written, created, renamed or removed anywhere, though files which are already open stay usable.  It can't be
+// undone.
+func sandboxReadOnly(readable []string) error {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return fmt.Errorf("landlock is not available: %v", errno)
+	}
+
+	handled := uint64(landlockAccessFSABI1)
+	if abi >= 2 {
+		handled |= landlockAccessFSRefer
+	}
+	if abi >= 3 {
+		handled |= landlockAccessFSTruncate
+	}
+	attr := landlockRulesetAttr{handledAccessFS: handled}
+	rulesetFd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)),
+		unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create landlock ruleset: %v", errno)
+	}
+	defer syscall.Close(int(rulesetFd))
+
+	for _, p := range readable {
+		fd, err := syscall.Open(p, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to open %v: %v", p, err)
+		}
+
+		// Directory access rights can only be granted on directories.
+		access := uint64(landlockAccessFSReadFile)
+		if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+			access |= landlockAccessFSReadDir
+		}
+		rule := landlockPathBeneathAttr{allowedAccess: access, parentFd: int32(fd)}
+		_, _, errno := syscall.Syscall6(sysLandlockAddRule, rulesetFd, landlockRulePathBeneath,
+			uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+		syscall.Close(fd)
+		if errno != 0 {
+			return fmt.Errorf("failed to add landlock rule for %v: %v", p, errno)
+		}
+	}
+
+	// Both calls only affect the calling thread, and go routines run on all of them.  Applying them to every thread
+	// isn't possible in binaries built with cgo.
+	if _, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		if errno == syscall.ENOTSUP {
+			return fmt.Errorf("bff must be built with CGO_ENABLED=0 to sandbox itself")
+		}
+		return fmt.Errorf("failed to set no_new_privs: %v", errno)
+	}
+	if _, _, errno := syscall.AllThreadsSyscall(sysLandlockRestrictSelf, rulesetFd, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to enforce landlock ruleset: %v", errno)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// sandboxReadOnly is only implemented on Linux, using Landlock.
+func sandboxReadOnly(readable []string) error {
+	return fmt.Errorf("sandboxing is not supported on %v", runtime.GOOS)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// jsonSchemaVersion is the version of the layout of the machine readable formats (json, yaml, ndjson and csv),
+// written as schema_version.  Adding fields keeps the version; renaming, removing or changing the meaning of a field
+// bumps it.
+const jsonSchemaVersion = 1
+
+// checkSchemaVersion returns an error unless bff writes version v of the machine readable formats, so tooling pinned
+// to a version fails loudly rather than misreading a newer layout.
+func checkSchemaVersion(v int) error {
+	if v != jsonSchemaVersion {
+		return fmt.Errorf("schema version %v was asked for, but this bff writes version %v", v, jsonSchemaVersion)
+	}
+	return nil
+}
+
+// schemaFor returns the JSON Schema of format, for -schema.
+func schemaFor(format string) (string, error) {
+	switch format {
+	case "json", "yaml":
+		return jsonSchema, nil
+	case formatNDJSON:
+		return ndjsonSchema, nil
+	case "csv":
+		return "", errors.New("the csv format has no JSON Schema; its columns are named by its header row, " +
+			"and schema_version is the last of those always written")
+	}
+	return "", fmt.Errorf("the %v format has no schema", format)
+}
+
+// jsonEntrySchema is the JSON Schema of a jsonRec, shared by the schemas below.
+const jsonEntrySchema = `{
+      "type": "object",
+      "required": ["path", "size", "type", "mtime", "depth", "parent", "rel_path"],
+      "properties": {
+        "path": {"type": "string"},
+        "size": {"type": "integer", "description": "Bytes; for a directory, the sum of its own entries."},
+        "type": {"enum": ["file", "dir", "symlink", "fifo", "socket", "chardev", "blockdev"]},
+        "mtime": {"type": "string", "format": "date-time"},
+        "newest_mtime": {"type": "string", "format": "date-time", "description": "Directories only."},
+        "future_dated": {"type": "boolean"},
+        "depth": {"type": "integer", "minimum": 0},
+        "parent": {"type": "string"},
+        "rel_path": {"type": "string"},
+        "owner": {"type": "string", "description": "With -long."},
+        "group": {"type": "string", "description": "With -long."},
+        "owners": {
+          "type": "array",
+          "description": "With -owners, the top owners of a directory's entries.",
+          "items": {
+            "type": "object",
+            "required": ["user", "uid", "bytes"],
+            "properties": {"user": {"type": "string"}, "uid": {"type": "integer"}, "bytes": {"type": "integer"}}
+          }
+        },
+        "held_by": {"type": "array", "items": {"type": "string"}, "description": "With -attribute-process."},
+        "annotation": {"type": "string", "description": "With -annotations."}
+      }
+    }`
+
+// jsonSchema is the JSON Schema of the json format.  The yaml format holds the same document.  Like the schemas below,
+// it must be kept in step with the types it describes, here jsonEnvelope and the types it's made of.
+const jsonSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "bff report",
+  "type": "object",
+  "required": ["schema_version", "run_id", "hostname", "root", "start", "end", "bff_version", "options", "results"],
+  "properties": {
+    "schema_version": {"const": 1},
+    "run_id": {"type": "string", "description": "Random UUID identifying the run."},
+    "hostname": {"type": "string"},
+    "root": {"type": "string", "description": "The scanned directory, as reported."},
+    "start": {"type": "string", "format": "date-time"},
+    "end": {"type": "string", "format": "date-time"},
+    "bff_version": {"type": "string"},
+    "options": {
+      "type": "object",
+      "description": "Every command line option, with the value in effect.",
+      "additionalProperties": {"type": "string"}
+    },
+    "results": {
+      "type": "object",
+      "required": ["root", "files", "dirs", "totals"],
+      "properties": {
+        "root": {"type": "string"},
+        "files": {"description": "The largest files, largest first, or null if not reported.",
+          "type": ["array", "null"], "items": {"$ref": "#/$defs/entry"}},
+        "dirs": {"description": "The largest directories by the size of their own entries, largest first, or null.",
+          "type": ["array", "null"], "items": {"$ref": "#/$defs/entry"}},
+        "special": {"type": "array", "items": {"$ref": "#/$defs/entry"}},
+        "system_files": {"type": "array", "items": {"$ref": "#/$defs/systemFile"}},
+        "totals": {
+          "type": "object",
+          "required": ["files", "dirs", "special", "file_bytes", "dir_bytes", "future_dated"],
+          "properties": {
+            "files": {"type": "integer"},
+            "dirs": {"type": "integer"},
+            "special": {"type": "integer"},
+            "file_bytes": {"type": "integer"},
+            "dir_bytes": {"type": "integer", "description": "Size of everything scanned."},
+            "future_dated": {"type": "integer"},
+            "coverage": {"type": "number", "minimum": 0, "maximum": 1,
+              "description": "Fraction of the directories found which were read, below 1 if -budget ran out."}
+          }
+        }
+      }
+    }
+  },
+  "$defs": {
+    "entry": ` + jsonEntrySchema + `,
+    "systemFile": {
+      "type": "object",
+      "required": ["path", "size", "kind"],
+      "properties": {
+        "path": {"type": "string"},
+        "size": {"type": "integer"},
+        "kind": {"enum": ["swap", "loop", "hibernation"]},
+        "device": {"type": "string"}
+      }
+    }
+  }
+}
+`
+
+// ndjsonSchema is the JSON Schema of each line of the ndjson format, an ndjsonRec.
+const ndjsonSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "bff ndjson entry",
+  "allOf": [{"$ref": "#/$defs/entry"}],
+  "required": ["schema_version", "run_id"],
+  "properties": {
+    "schema_version": {"const": 1},
+    "run_id": {"type": "string", "description": "Random UUID identifying the run."}
+  },
+  "$defs": {
+    "entry": ` + jsonEntrySchema + `
+  }
+}
+`
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// shardMin is the number of entries from which the contents of a directory are split between several go routines.
+const shardMin = 10000
+
+// walkContents walks the contents of directory fr, found at dirPath, whose entries are at depth, and returns the
+// newest mtime seen.
+// Huge flat directories are split by listing position into contiguous shards, walked concurrently, so a single
+// pathological directory doesn't serialize the whole scan.  When the number of workers is bounded, shards only get a
+// go routine of their own if there's a free walker slot for it, and are otherwise walked by the caller, in the slot
+// it already holds.
+func (s *Scanner) walkContents(fr *FileRec, dirPath string, depth int, fileRecCh chan *FileRec) time.Time {
+	shards := len(fr.Contents) / shardMin
+	if shards > runtime.NumCPU() {
+		shards = runtime.NumCPU()
+	}
+	if s.Workers > 0 && shards > s.Workers {
+		shards = s.Workers
+	}
+	if shards < 2 {
+		return s.walkEntries(fr.Contents, fr, dirPath, depth, fileRecCh)
+	}
+
+	newest := make([]time.Time, shards)
+	perShard := (len(fr.Contents) + shards - 1) / shards
+	shard := func(i int) []os.FileInfo {
+		lo, hi := i*perShard, (i+1)*perShard
+		if hi > len(fr.Contents) {
+			hi = len(fr.Contents)
+		}
+		return fr.Contents[lo:hi]
+	}
+	var wg sync.WaitGroup
+	inline := []int{0}
+	for i := 1; i < shards; i++ {
+		if !s.tryAcquireWorker() {
+			inline = append(inline, i)
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer s.releaseWorker()
+			newest[i] = s.walkEntries(shard(i), fr, dirPath, depth, fileRecCh)
+		}(i)
+	}
+	for _, i := range inline {
+		newest[i] = s.walkEntries(shard(i), fr, dirPath, depth, fileRecCh)
+	}
+	wg.Wait()
+
+	latest := time.Time{}
+	for _, t := range newest {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// walkEntries walks each of entries, found in directory dir at dirPath, one after the other, and returns the newest
+// mtime seen.
+func (s *Scanner) walkEntries(entries []os.FileInfo, dir *FileRec, dirPath string, depth int,
+	fileRecCh chan *FileRec) time.Time {
+
+	latest := time.Time{}
+	for _, e := range entries {
+		if t := s.Walk(e, dir, dirPath, depth, fileRecCh); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// tryAcquireWorker takes a walker slot if one is free, or if the number of workers isn't bounded, and reports
+// whether it did.  A slot taken must be given back with releaseWorker.
+func (s *Scanner) tryAcquireWorker() bool {
+	if s.workers == nil {
+		return true
+	}
+	select {
+	case s.workers <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseWorker gives back a walker slot taken by tryAcquireWorker.
+func (s *Scanner) releaseWorker() {
+	if s.workers != nil {
+		<-s.workers
+	}
+}
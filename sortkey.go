@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Sort keys accepted by -sort.
+const (
+	sortSize  = "size"
+	sortMtime = "mtime"
+	sortPath  = "path"
+	sortCount = "count"
+)
+
+// sortKeys maps each sort key to its natural order: largest, oldest or most entries first, or by path.  Future-dated
+// entries have no usable mtime, so they're kept apart, after the newest ones.
+var sortKeys = map[string]func(a, b *FileRec) bool{
+	sortSize: func(a, b *FileRec) bool { return a.Size > b.Size },
+	sortMtime: func(a, b *FileRec) bool {
+		if a.Future != b.Future {
+			return b.Future
+		}
+		return a.Newest.Before(b.Newest)
+	},
+	sortPath:  func(a, b *FileRec) bool { return comparePaths(a, b) < 0 },
+	sortCount: func(a, b *FileRec) bool { return a.Count > b.Count },
+}
+
+// checkSortKey returns an error if key isn't one of the sort keys.
+func checkSortKey(key string) error {
+	if _, ok := sortKeys[key]; !ok {
+		return fmt.Errorf("unknown sort key %q, use size, mtime, path or count", key)
+	}
+	return nil
+}
+
+// sortedBy returns a copy of res with Files and Dirs ordered by key, or the other way round if reverse is set.
+// Entries which compare equal stay largest first.  res itself is left as it is.
+func (res *Results) sortedBy(key string, reverse bool) *Results {
+	less := sortKeys[key]
+	sorted := *res
+	for _, recs := range []*[]*FileRec{&sorted.Files, &sorted.Dirs} {
+		*recs = append([]*FileRec(nil), *recs...)
+		sort.SliceStable(*recs, func(i, j int) bool {
+			if reverse {
+				return less((*recs)[j], (*recs)[i])
+			}
+			return less((*recs)[i], (*recs)[j])
+		})
+	}
+	return &sorted
+}
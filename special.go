@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+)
+
+// specialTypes are the file types which bff never opens and counts as zero bytes.  Opening a FIFO or a device can
+// block forever, and whatever size they report says nothing about disk usage.
+const specialTypes = os.ModeNamedPipe | os.ModeSocket | os.ModeDevice | os.ModeCharDevice
+
+// isSpecial reports whether fi describes a FIFO, socket or device file.
+func isSpecial(fi os.FileInfo) bool {
+	return fi.Mode()&specialTypes != 0
+}
+
+// specialKind returns a short name for the type of the special file described by fi, which is also the suffix of
+// its message key.
+func specialKind(fi os.FileInfo) string {
+	mode := fi.Mode()
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "chardev"
+	default:
+		return "blockdev"
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+)
+
+// fileID is unsupported on this platform, so every file is treated as unique.
+func fileID(fi os.FileInfo) (key fileKey, nlink uint64, ok bool) {
+	return fileKey{}, 0, false
+}
+
+// fileOwner is unsupported on this platform.
+func fileOwner(fi os.FileInfo) (uid, gid uint32, ok bool) {
+	return 0, 0, false
+}
+
+// allocated is unsupported on this platform.
+func allocated(fi os.FileInfo) (dev uint64, bytes int64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,16 @@
+//go:build darwin || freebsd
+
+package main
+
+import (
+	"syscall"
+)
+
+// fsUsed returns the bytes in use on the filesystem holding path, as df reports them.
+func fsUsed(path string) (int64, error) {
+	st := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return int64(uint64(st.Blocks-st.Bfree) * uint64(st.Bsize)), nil
+}
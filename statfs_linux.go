@@ -0,0 +1,15 @@
+package main
+
+import (
+	"syscall"
+)
+
+// fsUsed returns the bytes in use on the filesystem holding path, as df reports them.  Blocks are counted in
+// fragments, which only usually have the same size as the block size.
+func fsUsed(path string) (int64, error) {
+	st := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return int64((st.Blocks - st.Bfree) * uint64(st.Frsize)), nil
+}
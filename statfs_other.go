@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd)
+
+package main
+
+import (
+	"errors"
+)
+
+// fsUsed is unsupported on this platform.
+func fsUsed(path string) (int64, error) {
+	return 0, errors.New("filesystem usage isn't available on this platform")
+}
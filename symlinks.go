@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policies for attributing symlink sizes, as selected by -count-symlinks.
+const (
+	symlinksLink   = "link"   // A symlink counts as its own, small, size.
+	symlinksTarget = "target" // A symlink counts as its target's size, if the target is outside the scan.
+	symlinksSkip   = "skip"   // Symlinks are ignored altogether.
+)
+
+// checkSymlinkPolicy returns an error if policy isn't one of the symlink policies above.
+func checkSymlinkPolicy(policy string) error {
+	switch policy {
+	case symlinksLink, symlinksTarget, symlinksSkip:
+		return nil
+	}
+	return fmt.Errorf("unknown symlink policy %q, must be one of %v, %v or %v", policy, symlinksLink,
+		symlinksTarget, symlinksSkip)
+}
+
+// A sizedInfo overrides the size reported by an os.FileInfo.
+type sizedInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (si sizedInfo) Size() int64 {
+	return si.size
+}
+
+// resolveSymlink applies the scanner's symlink policy to the symlink at path, described by fi.  It returns the
+// os.FileInfo to account the symlink with, or nil if it should be left out.
+//
+// With the target policy, targets inside the scan count as zero bytes, as they're counted at their own paths whatever
+// order the scan finds them in.  So do targets which can't be resolved, and those outside the scan which were already
+// counted through another link.  A directory target counts as the size of the directory itself, as reported by stat,
+// not of the tree below it, which isn't walked.
+func (s *Scanner) resolveSymlink(path string, fi os.FileInfo) os.FileInfo {
+	switch s.Symlinks {
+	case symlinksSkip:
+		return nil
+	case symlinksTarget:
+		target, err := os.Stat(path)
+		if err != nil || s.inScan(path) || !s.visited.Visit(target) {
+			return sizedInfo{FileInfo: fi, size: 0}
+		}
+		return sizedInfo{FileInfo: fi, size: target.Size()}
+	}
+	return fi
+}
+
+// inScan reports whether the symlink at path leads to somewhere at or below the root of the current scan.
+func (s *Scanner) inScan(path string) bool {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(s.root, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Kinds of system file, which are also the suffixes of their message keys.
+const (
+	systemSwap        = "swap"
+	systemLoop        = "loop"
+	systemHibernation = "hibernation"
+)
+
+// A systemFile is a file the operating system uses for itself, such as a swap file or the image behind a loop
+// device.  It looks like any other large file, but can't simply be deleted.
+type systemFile struct {
+	Path   string
+	Kind   string // One of the system* kinds.
+	Device string // The loop device, for loop images.
+	Size   int64
+}
+
+// findSystemFiles returns the system files in use below root, largest first.
+func findSystemFiles(root string) []*systemFile {
+	found := []*systemFile{}
+	for _, sf := range systemFiles(root) {
+		if rel, err := filepath.Rel(root, sf.Path); err != nil || rel == ".." || strings.HasPrefix(rel, "../") ||
+			strings.HasPrefix(rel, `..\`) {
+			continue
+		}
+		fi, err := os.Lstat(sf.Path)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		sf.Size = fi.Size()
+		found = append(found, sf)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Size > found[j].Size })
+	return found
+}
+
+// note returns the guidance for sf in the report's language.
+func (sf *systemFile) note(opts *reportOptions) string {
+	if sf.Kind == systemLoop {
+		return opts.msg("system."+sf.Kind, sf.Device)
+	}
+	return opts.msg("system." + sf.Kind)
+}
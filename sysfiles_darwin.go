@@ -0,0 +1,15 @@
+package main
+
+import (
+	"path/filepath"
+)
+
+// systemFiles returns the swap files created by dynamic_pager, and the hibernation image.
+func systemFiles(root string) []*systemFile {
+	files := []*systemFile{{Path: "/private/var/vm/sleepimage", Kind: systemHibernation}}
+	swaps, _ := filepath.Glob("/private/var/vm/swapfile*")
+	for _, s := range swaps {
+		files = append(files, &systemFile{Path: s, Kind: systemSwap})
+	}
+	return files
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// systemFiles returns the swap files listed in /proc/swaps and the images behind loop devices.
+func systemFiles(root string) []*systemFile {
+	files := []*systemFile{}
+	if f, err := os.Open("/proc/swaps"); err == nil {
+		defer f.Close()
+		// Lines look like "/swapfile  file  2097148  0  -2", after a header.  Spaces in the path are escaped as \040.
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			fields := strings.Fields(sc.Text())
+			if len(fields) >= 2 && fields[1] == "file" {
+				path := strings.ReplaceAll(fields[0], `\040`, " ")
+				files = append(files, &systemFile{Path: path, Kind: systemSwap})
+			}
+		}
+	}
+	backing, _ := filepath.Glob("/sys/block/loop*/loop/backing_file")
+	for _, b := range backing {
+		data, err := os.ReadFile(b)
+		if err != nil {
+			continue
+		}
+		path := strings.TrimSpace(string(data))
+		if strings.HasSuffix(path, " (deleted)") {
+			continue
+		}
+		device := "/dev/" + filepath.Base(filepath.Dir(filepath.Dir(b)))
+		files = append(files, &systemFile{Path: path, Kind: systemLoop, Device: device})
+	}
+	return files
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+// systemFiles knows of no system files on this platform.
+func systemFiles(root string) []*systemFile {
+	return nil
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"path/filepath"
+)
+
+// systemFiles returns the paging, swap and hibernation files at the top of the volume holding root.
+func systemFiles(root string) []*systemFile {
+	vol := filepath.VolumeName(root) + `\`
+	return []*systemFile{
+		{Path: vol + "pagefile.sys", Kind: systemSwap},
+		{Path: vol + "swapfile.sys", Kind: systemSwap},
+		{Path: vol + "hiberfil.sys", Kind: systemHibernation},
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+)
+
+// underAny reports whether path is one of prefixes, or below one of them.
+func underAny(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// tccBlocked returns those of the denied paths which were most likely blocked by TCC rather than by file
+// permissions.
+func tccBlocked(denied []string) []string {
+	protected := tccProtectedPaths()
+	blocked := []string{}
+	for _, p := range denied {
+		if underAny(p, protected) {
+			blocked = append(blocked, p)
+		}
+	}
+	return blocked
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// tccProtectedPaths returns the locations macOS guards with TCC (Transparency, Consent, and Control).  Unless the
+// terminal running bff has been granted Full Disk Access, reads below them fail with permission errors even for
+// their owner.
+func tccProtectedPaths() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	paths := []string{"/Volumes"}
+	for _, p := range []string{
+		"Desktop", "Documents", "Downloads", "Pictures", "Movies", "Music",
+		"Library/Mail", "Library/Messages", "Library/Safari", "Library/Calendars", "Library/Reminders",
+		"Library/Application Support/AddressBook", "Library/Application Support/MobileSync",
+		"Library/Application Support/com.apple.TCC", "Library/Mobile Documents", "Library/HomeKit",
+		"Library/IdentityServices", "Library/Metadata/CoreSpotlight", "Library/Suggestions",
+	} {
+		paths = append(paths, filepath.Join(home, p))
+	}
+	return paths
+}
+
+// tccRemedy tells the user how to stop TCC from blocking the scan.
+const tccRemedy = "grant Full Disk Access to your terminal in System Settings > Privacy & Security > " +
+	"Full Disk Access, then restart it, or pass -skip-tcc-protected to leave these locations out"
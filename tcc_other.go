@@ -0,0 +1,11 @@
+//go:build !darwin
+
+package main
+
+// tccProtectedPaths returns nothing, as TCC only exists on macOS.
+func tccProtectedPaths() []string {
+	return nil
+}
+
+// tccRemedy is never shown outside of macOS.
+const tccRemedy = ""
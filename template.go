@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"text/template"
+)
+
+// A templateRec is what a -template is executed with for each entry.  It has the fields and methods of FileRec, with
+// paths as they should appear in the report.
+type templateRec struct {
+	*FileRec
+	Path    string // The path, mapped and redacted.
+	Parent  string // The path of the containing directory, mapped and redacted.
+	RelPath string // The path relative to the scan root, redacted.
+	Kind    string // One of the types written by fileType, e.g. "file" or "dir".
+}
+
+// templateFuncs are the functions available to a -template, on top of the text/template builtins.
+var templateFuncs = template.FuncMap{
+	"human": humanSize,
+}
+
+// newTemplateWriter parses text as a text/template and returns a reportWriter executing it once per reported entry,
+// each followed by a newline.
+func newTemplateWriter(text string) (reportWriter, error) {
+	t, err := template.New("entry").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return func(w io.Writer, res *Results, opts *reportOptions) error {
+		bw := bufio.NewWriter(w)
+		for _, section := range []struct {
+			include bool
+			recs    []*FileRec
+		}{{opts.Files, res.Files}, {opts.Dirs, res.Dirs}, {opts.Special, res.Special}} {
+			if !section.include {
+				continue
+			}
+			for _, fr := range section.recs {
+				tr := &templateRec{
+					FileRec: fr,
+					Path:    opts.path(fr),
+					Parent:  opts.mapPath(fr.Parent()),
+					RelPath: opts.Redact.Redact(filepath.ToSlash(fr.RelPath(res.Root.Path()))),
+					Kind:    fileType(fr.FileInfo),
+				}
+				if err := t.Execute(bw, tr); err != nil {
+					return err
+				}
+				bw.WriteByte('\n')
+			}
+		}
+		return bw.Flush()
+	}, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"container/heap"
+	"log"
+	"sort"
+)
+
+// A treeNode is a directory in a sizeTree.
+type treeNode struct {
+	Rec      *FileRec
+	Total    int64       // Size of the directory and everything below it.
+	Children []*treeNode // Subdirectories, largest Total first.
+}
+
+// A sizeTree collects every directory of a scan, so the hierarchy can be exported with cumulative sizes.  It keeps
+// one node per directory, so collection stops if memory runs short.
+type sizeTree struct {
+	root  *treeNode
+	nodes map[*FileRec]*treeNode
+	shed  bool // Set if collection stopped early, leaving the tree incomplete.
+}
+
+func newSizeTree(root *FileRec) *sizeTree {
+	rn := &treeNode{Rec: root}
+	return &sizeTree{root: rn, nodes: map[*FileRec]*treeNode{root: rn}}
+}
+
+// Add records fr if it's a directory.  It's meant to be used as a Scanner's Each function.
+func (st *sizeTree) Add(fr *FileRec) {
+	if st.shed || !fr.FileInfo.IsDir() {
+		return
+	}
+	if memory.Shedding() {
+		log.Printf("dropping the directory tree to save memory")
+		st.shed, st.nodes = true, nil
+		return
+	}
+	st.nodes[fr] = &treeNode{Rec: fr}
+}
+
+// Build links the collected directories together and works out their totals.  It returns false if the tree is
+// incomplete.
+func (st *sizeTree) Build() bool {
+	if st.shed {
+		return false
+	}
+	for fr, n := range st.nodes {
+		if n == st.root {
+			continue
+		}
+		if parent, ok := st.nodes[fr.Dir]; ok {
+			parent.Children = append(parent.Children, n)
+		}
+	}
+	st.root.total()
+	return true
+}
+
+// total works out the Total of n and all of its descendants, and sorts its children.
+func (n *treeNode) total() int64 {
+	n.Total = n.Rec.Size
+	for _, c := range n.Children {
+		n.Total += c.total()
+	}
+	sort.Slice(n.Children, func(i, j int) bool {
+		if n.Children[i].Total != n.Children[j].Total {
+			return n.Children[i].Total > n.Children[j].Total
+		}
+		return comparePaths(n.Children[i].Rec, n.Children[j].Rec) < 0
+	})
+	return n.Total
+}
+
+// nodeHeap is a max-heap of treeNodes by Total.
+type nodeHeap []*treeNode
+
+func (nh nodeHeap) Len() int            { return len(nh) }
+func (nh nodeHeap) Less(i, j int) bool  { return nh[i].Total > nh[j].Total }
+func (nh nodeHeap) Swap(i, j int)       { nh[i], nh[j] = nh[j], nh[i] }
+func (nh *nodeHeap) Push(x interface{}) { *nh = append(*nh, x.(*treeNode)) }
+func (nh *nodeHeap) Pop() interface{} {
+	old := *nh
+	n := old[len(old)-1]
+	*nh = old[:len(old)-1]
+	return n
+}
+
+// Top returns the max heaviest directories of the tree, always including the parents of those returned, so they
+// form a tree themselves.  The root comes first.
+func (st *sizeTree) Top(max int) map[*treeNode]bool {
+	top := map[*treeNode]bool{}
+	frontier := &nodeHeap{st.root}
+	for frontier.Len() > 0 && len(top) < max {
+		n := heap.Pop(frontier).(*treeNode)
+		top[n] = true
+		for _, c := range n.Children {
+			heap.Push(frontier, c)
+		}
+	}
+	return top
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"sync"
+)
+
+// A fileKey identifies a file system object independently of the path it was reached through.
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// A visitedSet records the objects seen during a scan, so that one reachable through more than one path (hard
+// links, bind mounts, mount loops) is counted and walked only once.  It's safe for concurrent use.
+type visitedSet struct {
+	mu   sync.Mutex
+	seen map[fileKey]struct{}
+	all  bool // Record every object, not just those which can be reached through more than one path.
+}
+
+// newVisitedSet returns an empty visitedSet.  If all is set, every object visited is recorded, which is needed when
+// symlinks make any file reachable twice.
+func newVisitedSet(all bool) *visitedSet {
+	return &visitedSet{seen: map[fileKey]struct{}{}, all: all}
+}
+
+// Visit marks fi as seen and reports whether this is the first time it has been.  Unless the set records all
+// objects, only directories and files with more than one link are recorded, as nothing else can be reached twice.
+// Objects which can't be identified on this platform are always reported as first visits.
+func (vs *visitedSet) Visit(fi os.FileInfo) bool {
+	key, nlink, ok := fileID(fi)
+	if !ok || (!vs.all && !fi.IsDir() && nlink <= 1) {
+		return true
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	if _, dup := vs.seen[key]; dup {
+		return false
+	}
+	vs.seen[key] = struct{}{}
+	return true
+}
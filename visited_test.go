@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Every hard link to a file is found, but the file is only counted once, however the links are spread over the
+// directories walked concurrently.
+func TestScanCountsHardLinksOnce(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, "a/file")
+	for i := 0; i < 20; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("d%v", i))
+		if err := os.Mkdir(sub, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Link(filepath.Join(dir, "a", "file"), filepath.Join(sub, "link")); err != nil {
+			t.Skipf("can't create hard links: %v", err)
+		}
+	}
+
+	for run := 0; run < 10; run++ {
+		root, err := NewFileRec(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := (&Scanner{}).Scan(root)
+		if res.Stats.FileCount != 1 || res.Stats.FileBytes != int64(len("a/file")) {
+			t.Fatalf("scan counted %v files of %v bytes, want 1 of %v", res.Stats.FileCount, res.Stats.FileBytes,
+				len("a/file"))
+		}
+	}
+}
+
+// A directory reachable through two paths, as with a bind mount, is walked through just one of them.
+func TestScanWalksDirectoryReachableTwiceOnce(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, "real/one", "real/sub/two")
+	if err := os.Symlink("real", filepath.Join(dir, "mount")); err != nil {
+		t.Skipf("can't create symlinks: %v", err)
+	}
+
+	root, err := NewFileRec(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Listed through the symlink, mount looks like a second directory with real's identity, much as a bind mount
+	// would.
+	for i, e := range root.Contents {
+		if e.Name() == "mount" {
+			if root.Contents[i], err = os.Stat(filepath.Join(dir, "mount")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	res := (&Scanner{}).Scan(root)
+	if want := int64(len("real/one") + len("real/sub/two")); res.Stats.FileCount != 2 || res.Stats.FileBytes != want {
+		t.Errorf("scan counted %v files of %v bytes, want 2 of %v", res.Stats.FileCount, res.Stats.FileBytes, want)
+	}
+	if res.Stats.DirCount != 3 {
+		t.Errorf("scan counted %v directories, want 3", res.Stats.DirCount)
+	}
+}
+
+// A directory containing a way back to itself, as a mount loop does, isn't walked again.
+func TestScanStopsAtLoops(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, "top/file")
+	if err := os.Symlink(".", filepath.Join(dir, "loop")); err != nil {
+		t.Skipf("can't create symlinks: %v", err)
+	}
+
+	root, err := NewFileRec(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Listed through the symlink, loop looks like a directory with the root's identity, much as a mount of the root
+	// below itself would.
+	for i, e := range root.Contents {
+		if e.Name() == "loop" {
+			if root.Contents[i], err = os.Stat(filepath.Join(dir, "loop")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	res := (&Scanner{}).Scan(root)
+	if res.Stats.FileCount != 1 || res.Stats.DirCount != 2 || res.Stats.Errors != 0 {
+		t.Errorf("scan counted %v files and %v directories with %v errors, want 1 and 2 without errors",
+			res.Stats.FileCount, res.Stats.DirCount, res.Stats.Errors)
+	}
+}
+
+// Of many go routines visiting the same objects at once, exactly one gets the first visit of each.  Run with -race.
+func TestVisitConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	infos := []os.FileInfo{}
+	for i := 0; i < 50; i++ {
+		p := filepath.Join(dir, fmt.Sprintf("d%v", i))
+		if err := os.Mkdir(p, 0755); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		infos = append(infos, fi)
+	}
+	if _, _, ok := fileID(infos[0]); !ok {
+		t.Skip("files can't be identified on this platform")
+	}
+
+	vs := newVisitedSet(false)
+	firsts := make([]int32, len(infos))
+	wg := sync.WaitGroup{}
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := range infos {
+				i := (i + g) % len(infos)
+				if vs.Visit(infos[i]) {
+					atomic.AddInt32(&firsts[i], 1)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	for i, n := range firsts {
+		if n != 1 {
+			t.Errorf("%v had %v first visits, want 1", infos[i].Name(), n)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// yamlPlain matches strings which can be written as plain YAML scalars without being mistaken for anything else.
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_/.][A-Za-z0-9_/.@+-]*$`)
+
+// yamlReserved are plain scalars which YAML parsers read as something other than a string.
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true, "y": true, "n": true,
+	"null": true, "~": true, ".inf": true, ".nan": true,
+}
+
+// yamlNumeric matches strings allowed by yamlPlain which YAML parsers nevertheless read as numbers, e.g. ".5".
+var yamlNumeric = regexp.MustCompile(`^\.[0-9_]`)
+
+// yamlString returns s as a YAML scalar, quoted only if it has to be.
+func yamlString(s string) string {
+	_, err := strconv.ParseFloat(s, 64)
+	if yamlPlain.MatchString(s) && !yamlReserved[strings.ToLower(s)] && !yamlNumeric.MatchString(s) && err != nil {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// A yamlField is a struct field as it appears in YAML.  Field names and omitempty come from the json tags, so the
+// YAML output mirrors the JSON one.
+type yamlField struct {
+	name  string
+	value reflect.Value
+}
+
+// yamlFields returns the fields of struct v to write, in declaration order.  Embedded structs are flattened.
+func yamlFields(v reflect.Value) []yamlField {
+	fields := []yamlField{}
+	for i := 0; i < v.NumField(); i++ {
+		sf, fv := v.Type().Field(i), v.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if sf.Anonymous && name == "" {
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				fields = append(fields, yamlFields(fv)...)
+			}
+			continue
+		}
+		if name == "-" || (opts == "omitempty" && fv.IsZero()) ||
+			(opts == "omitempty" && fv.Kind() == reflect.Slice && fv.Len() == 0) {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, yamlField{name: name, value: fv})
+	}
+	return fields
+}
+
+// yamlScalar returns v as a YAML scalar, and false if v isn't a scalar.
+func yamlScalar(v reflect.Value) (string, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "null", true
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return yamlString(t.Format(time.RFC3339Nano)), true
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return yamlString(v.String()), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), true
+	case reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return "null", true
+		}
+		if v.Kind() == reflect.Slice && v.Len() == 0 {
+			return "[]", true
+		}
+		if v.Kind() == reflect.Map && v.Len() == 0 {
+			return "{}", true
+		}
+	}
+	return "", false
+}
+
+// writeYAMLValue writes v at the given indentation.  inline is set when the caller already wrote a "- " for v, so
+// its first line must not be indented.
+func writeYAMLValue(w *bufio.Writer, v reflect.Value, indent int, inline bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			break
+		}
+		v = v.Elem()
+	}
+	pad := strings.Repeat("  ", indent)
+	first := func() string {
+		if inline {
+			inline = false
+			return ""
+		}
+		return pad
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range yamlFields(v) {
+			writeYAMLEntry(w, first(), f.name, f.value, indent)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			writeYAMLEntry(w, first(), k.String(), v.MapIndex(k), indent)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if s, ok := yamlScalar(v.Index(i)); ok {
+				fmt.Fprintf(w, "%v- %v\n", first(), s)
+				continue
+			}
+			fmt.Fprintf(w, "%v- ", first())
+			writeYAMLValue(w, v.Index(i), indent+1, true)
+		}
+	}
+}
+
+// writeYAMLEntry writes one "key: value" mapping entry, with the given prefix before the key.
+func writeYAMLEntry(w *bufio.Writer, prefix, key string, v reflect.Value, indent int) {
+	if s, ok := yamlScalar(v); ok {
+		fmt.Fprintf(w, "%v%v: %v\n", prefix, yamlString(key), s)
+		return
+	}
+	fmt.Fprintf(w, "%v%v:\n", prefix, yamlString(key))
+	writeYAMLValue(w, v, indent+1, false)
+}
+
+// writeYAML writes res to w as a YAML document, with the same structure as the JSON output.
+func writeYAML(w io.Writer, res *Results, opts *reportOptions) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "---")
+	writeYAMLValue(bw, reflect.ValueOf(jsonEnvelope{SchemaVersion: jsonSchemaVersion, runInfo: opts.Run,
+		Results: newJSONReport(res, opts)}), 0, false)
+	return bw.Flush()
+}
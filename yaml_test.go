@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+// Strings which YAML parsers would read as anything but strings must be quoted, and the others needn't be.
+func TestYAMLString(t *testing.T) {
+	for s, want := range map[string]string{
+		"foo":          "foo",
+		"/srv/data.db": "/srv/data.db",
+		".":            ".",
+		".bashrc":      ".bashrc",
+		"d1/.5":        "d1/.5",
+		".5":           `".5"`,
+		".5e3":         `".5e3"`,
+		"._5":          `"._5"`,
+		".inf":         `".inf"`,
+		".NaN":         `".NaN"`,
+		"Infinity":     `"Infinity"`,
+		"true":         `"true"`,
+		"No":           `"No"`,
+		"":             `""`,
+		"12":           `"12"`,
+		"a b":          `"a b"`,
+	} {
+		if got := yamlString(s); got != want {
+			t.Errorf("yamlString(%q) = %v, want %v", s, got, want)
+		}
+	}
+}